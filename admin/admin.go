@@ -0,0 +1,170 @@
+// Package admin serves the endpoints orchestrators such as Kubernetes probe
+// the dispatcher with: /healthz, /readyz and /status.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"data-enricher-dispatcher/client"
+	"data-enricher-dispatcher/scheduler"
+	"data-enricher-dispatcher/service"
+)
+
+const (
+	defaultProbeTimeout = 5 * time.Second
+	defaultPingTTL      = 15 * time.Second
+
+	checkOK    = "ok"
+	readyOK    = "ready"
+	readyNotOK = "not ready"
+	lastRunBad = "last run failed: "
+)
+
+// Readiness is the body of /readyz: the overall verdict and the result of
+// every check, "ok" or why it failed.
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Status is the body of /status. Schedule is only set in daemon mode.
+type Status struct {
+	service.Status
+	Schedule *scheduler.Status `json:"schedule,omitempty"`
+}
+
+type handler struct {
+	dispatcher   service.StatusReporter
+	source       client.Pinger
+	scheduler    *scheduler.Scheduler
+	probeTimeout time.Duration
+	pingTTL      time.Duration
+
+	// pingMu guards the outcome of the last ping, which probes arriving
+	// within pingTTL of it reuse.
+	pingMu  sync.Mutex
+	pingAt  time.Time
+	pingErr error
+}
+
+// Option customizes a handler created by NewHandler.
+type Option func(*handler)
+
+// WithSource makes readiness depend on source answering a ping.
+func WithSource(source client.Pinger) Option {
+	return func(h *handler) {
+		h.source = source
+	}
+}
+
+// WithScheduler adds the daemon's schedule to /status.
+func WithScheduler(s *scheduler.Scheduler) Option {
+	return func(h *handler) {
+		h.scheduler = s
+	}
+}
+
+// WithProbeTimeout bounds how long the source may take to answer the ping of
+// a readiness probe.
+func WithProbeTimeout(timeout time.Duration) Option {
+	return func(h *handler) {
+		h.probeTimeout = timeout
+	}
+}
+
+// WithPingTTL sets how long the outcome of a ping of the source is reused by
+// the following readiness probes, as every ping fetches the first page of
+// the source. Zero pings on every probe.
+func WithPingTTL(ttl time.Duration) Option {
+	return func(h *handler) {
+		h.pingTTL = ttl
+	}
+}
+
+// NewHandler serves the admin endpoints for dispatcher. It is only created
+// once the configuration was loaded, which readiness takes for granted.
+func NewHandler(dispatcher service.StatusReporter, opts ...Option) http.Handler {
+	h := &handler{dispatcher: dispatcher, probeTimeout: defaultProbeTimeout, pingTTL: defaultPingTTL}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", h.healthz)
+	mux.HandleFunc("GET /readyz", h.readyz)
+	mux.HandleFunc("GET /status", h.status)
+	return mux
+}
+
+// healthz answers as long as the process is able to serve requests.
+func (h *handler) healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	//nolint:errcheck
+	w.Write([]byte(checkOK + "\n"))
+}
+
+// readyz reports whether the source is reachable and the last run did not
+// fail, answering 503 when either is not the case.
+func (h *handler) readyz(w http.ResponseWriter, r *http.Request) {
+	readiness := Readiness{Status: readyOK, Checks: map[string]string{"config": checkOK, "source": checkOK, "last_run": checkOK}}
+	fail := func(check, reason string) {
+		readiness.Status = readyNotOK
+		readiness.Checks[check] = reason
+	}
+
+	if h.source != nil {
+		if err := h.pingSource(r.Context()); err != nil {
+			fail("source", err.Error())
+		}
+	}
+	if last := h.dispatcher.Status().LastRun; last != nil && last.Status == service.RunStatusFailed {
+		fail("last_run", lastRunBad+last.Error)
+	}
+
+	code := http.StatusOK
+	if readiness.Status != readyOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, readiness)
+}
+
+// pingSource pings the source unless the last ping is recent enough to be
+// reused. Probes arriving during a ping wait for it and share its outcome.
+// A ping cut short by the probe going away is not remembered.
+func (h *handler) pingSource(ctx context.Context) error {
+	h.pingMu.Lock()
+	defer h.pingMu.Unlock()
+	if !h.pingAt.IsZero() && time.Since(h.pingAt) < h.pingTTL {
+		return h.pingErr
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, h.probeTimeout)
+	defer cancel()
+	err := h.source.Ping(pingCtx)
+	if ctx.Err() == nil {
+		h.pingAt, h.pingErr = time.Now(), err
+	}
+	return err
+}
+
+// status reports the phase of the dispatcher, the users in flight and the
+// last run report, along with the schedule in daemon mode.
+func (h *handler) status(w http.ResponseWriter, _ *http.Request) {
+	status := Status{Status: h.dispatcher.Status()}
+	if h.scheduler != nil {
+		schedule := h.scheduler.Status()
+		status.Schedule = &schedule
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	//nolint:errcheck
+	json.NewEncoder(w).Encode(body)
+}
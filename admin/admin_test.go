@@ -0,0 +1,207 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/scheduler"
+	"data-enricher-dispatcher/service"
+)
+
+type fixedStatus service.Status
+
+func (s fixedStatus) Status() service.Status {
+	return service.Status(s)
+}
+
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(...interface{})   {}
+func (nopLogger) Fatal(...interface{})   {}
+func (nopLogger) Println(...interface{}) {}
+func (nopLogger) Error(...interface{})   {}
+func (nopLogger) Info(...interface{})    {}
+func (nopLogger) Warn(...interface{})    {}
+
+func serve(t *testing.T, handler http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+func TestHealthz(t *testing.T) {
+	recorder := serve(t, NewHandler(fixedStatus{}), "/healthz")
+	if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != "ok" {
+		t.Errorf("expected 200 ok, got %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	reachable := pingFunc(func(context.Context) error { return nil })
+	unreachable := pingFunc(func(context.Context) error { return errors.New("connection refused") })
+	hanging := pingFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	tests := []struct {
+		name     string
+		status   service.Status
+		opts     []Option
+		wantCode int
+		failed   map[string]string
+	}{
+		{name: "before the first run", opts: []Option{WithSource(reachable)}, wantCode: http.StatusOK},
+		{name: "without a source check", wantCode: http.StatusOK},
+		{
+			name:     "last run succeeded",
+			status:   service.Status{LastRun: &service.RunReport{Status: service.RunStatusSucceeded}},
+			opts:     []Option{WithSource(reachable)},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "last run partial",
+			status:   service.Status{LastRun: &service.RunReport{Status: service.RunStatusPartial}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "last run failed",
+			status:   service.Status{LastRun: &service.RunReport{Status: service.RunStatusFailed, Error: "boom"}},
+			wantCode: http.StatusServiceUnavailable,
+			failed:   map[string]string{"last_run": "last run failed: boom"},
+		},
+		{
+			name:     "source unreachable",
+			opts:     []Option{WithSource(unreachable)},
+			wantCode: http.StatusServiceUnavailable,
+			failed:   map[string]string{"source": "connection refused"},
+		},
+		{
+			name:     "source too slow",
+			opts:     []Option{WithSource(hanging), WithProbeTimeout(time.Millisecond)},
+			wantCode: http.StatusServiceUnavailable,
+			failed:   map[string]string{"source": context.DeadlineExceeded.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(t, NewHandler(fixedStatus(tt.status), tt.opts...), "/readyz")
+			if recorder.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, recorder.Code)
+			}
+			var readiness Readiness
+			if err := json.Unmarshal(recorder.Body.Bytes(), &readiness); err != nil {
+				t.Fatalf("unexpected body %q: %v", recorder.Body.String(), err)
+			}
+			for _, check := range []string{"config", "source", "last_run"} {
+				want, failed := tt.failed[check]
+				if !failed {
+					want = checkOK
+				}
+				if got := readiness.Checks[check]; got != want {
+					t.Errorf("expected check %s to be %q, got %q", check, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestReadyzReusesPing(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantPings int
+	}{
+		{name: "within the ttl", wantPings: 1},
+		{name: "without a ttl", opts: []Option{WithPingTTL(0)}, wantPings: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pings := 0
+			source := pingFunc(func(context.Context) error {
+				pings++
+				return errors.New("connection refused")
+			})
+			handler := NewHandler(fixedStatus{}, append([]Option{WithSource(source)}, tt.opts...)...)
+			for i := 0; i < 3; i++ {
+				if recorder := serve(t, handler, "/readyz"); recorder.Code != http.StatusServiceUnavailable {
+					t.Errorf("probe %d: expected status %d, got %d", i+1, http.StatusServiceUnavailable, recorder.Code)
+				}
+			}
+			if pings != tt.wantPings {
+				t.Errorf("expected %d pings, got %d", tt.wantPings, pings)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	every, err := scheduler.Every(time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	schedule := scheduler.New(every, func(context.Context) (string, error) { return "", nil }, nopLogger{})
+	status := service.Status{
+		Phase:    service.PhaseDispatching,
+		Mode:     service.RunModeDispatch,
+		Fetched:  10,
+		InFlight: 2,
+		Finished: 8,
+		LastRun:  &service.RunReport{Status: service.RunStatusPartial, Failed: 1},
+	}
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		wantSchedule bool
+	}{
+		{name: "single run"},
+		{name: "daemon", opts: []Option{WithScheduler(schedule)}, wantSchedule: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(t, NewHandler(fixedStatus(status), tt.opts...), "/status")
+			if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("expected a JSON 200, got %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("unexpected body %q: %v", recorder.Body.String(), err)
+			}
+			for field, want := range map[string]string{"phase": `"dispatching"`, "mode": `"dispatch"`, "fetched": "10", "in_flight": "2", "finished": "8"} {
+				if string(body[field]) != want {
+					t.Errorf("expected %s to be %s, got %s", field, want, body[field])
+				}
+			}
+			var lastRun service.RunReport
+			if err := json.Unmarshal(body["last_run"], &lastRun); err != nil || lastRun.Status != service.RunStatusPartial {
+				t.Errorf("expected the last run report, got %s", body["last_run"])
+			}
+			if _, ok := body["schedule"]; ok != tt.wantSchedule {
+				t.Errorf("expected schedule %v, got %s", tt.wantSchedule, body["schedule"])
+			}
+		})
+	}
+}
+
+func TestUnknownPath(t *testing.T) {
+	if recorder := serve(t, NewHandler(fixedStatus{}), "/metrics"); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", recorder.Code)
+	}
+}
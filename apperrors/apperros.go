@@ -1,6 +1,7 @@
 package apperrors
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 )
@@ -9,6 +10,8 @@ type AppError struct {
 	Message  string
 	Code     string
 	HTTPCode int
+
+	cause error
 }
 
 var (
@@ -24,6 +27,12 @@ var (
 		HTTPCode: http.StatusInternalServerError,
 	}
 
+	EnvConfigValidateError = AppError{
+		Message:  "Invalid configuration value in env file",
+		Code:     "ENV_VALIDATE_ERR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+
 	EnvConfigPostgresParseError = AppError{
 		Message:  "Failed to parse pastgres env file",
 		Code:     "ENV_POSTGRES_PARSE_ERR",
@@ -35,19 +44,52 @@ func (appError *AppError) Error() string {
 	return appError.Code + ": " + appError.Message
 }
 
+// AppendMessage returns a copy of appError with anyErrs appended to its
+// message. The first error among anyErrs is kept as the cause, so it stays
+// reachable through errors.Is and errors.As.
 func (appError *AppError) AppendMessage(anyErrs ...interface{}) *AppError {
+	var cause error
+	for _, anyErr := range anyErrs {
+		if err, ok := anyErr.(error); ok {
+			cause = err
+			break
+		}
+	}
+
 	return &AppError{
 		Message:  fmt.Sprintf("%v : %v", appError.Message, anyErrs),
 		Code:     appError.Code,
 		HTTPCode: appError.HTTPCode,
+		cause:    cause,
 	}
 }
 
+func (appError *AppError) Unwrap() error {
+	return appError.cause
+}
+
+// Is reports whether any *AppError in err1's chain has the code of err2.
 func Is(err1 error, err2 *AppError) bool {
-	err, ok := err1.(*AppError)
-	if !ok {
-		return false
+	for err1 != nil {
+		var err *AppError
+		if !errors.As(err1, &err) {
+			return false
+		}
+		if err.Code == err2.Code {
+			return true
+		}
+		err1 = err.cause
 	}
 
-	return err.Code == err2.Code
+	return false
+}
+
+// CodeOf returns the code of the first *AppError in err's chain, or an empty
+// string when there is none.
+func CodeOf(err error) string {
+	var appError *AppError
+	if errors.As(err, &appError) {
+		return appError.Code
+	}
+	return ""
 }
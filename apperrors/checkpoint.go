@@ -0,0 +1,16 @@
+package apperrors
+
+import "net/http"
+
+var (
+	CheckpointReadError = &AppError{
+		Message:  "Failed to read dispatch checkpoint",
+		Code:     "CHECKPOINT_READ_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	CheckpointWriteError = &AppError{
+		Message:  "Failed to write dispatch checkpoint",
+		Code:     "CHECKPOINT_WRITE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+)
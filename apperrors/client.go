@@ -43,6 +43,16 @@ var (
 		Code:     "ATTEMPTS_EXCEEDED_ERROR",
 		HTTPCode: http.StatusInternalServerError,
 	}
+	ApiClientGetUsersPaginationError = &AppError{
+		Message:  "Failed to build next page URL for getting users from API",
+		Code:     "API_CLIENT_GET_USERS_PAGINATION_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ApiClientGetUsersMaxPagesExceededError = &AppError{
+		Message:  "Maximum number of pages exceeded while getting users from API",
+		Code:     "API_CLIENT_GET_USERS_MAX_PAGES_EXCEEDED_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
 	ApiClientPostUserMarshalError = &AppError{
 		Message:  "Failed to marshal user data for API",
 		Code:     "API_CLIENT_POST_USER_MARSHAL_ERROR",
@@ -103,4 +113,49 @@ var (
 		Code:     "API_CLIENT_MAKE_REQUEST_WITH_CONTEXT_DO_ERROR",
 		HTTPCode: http.StatusInternalServerError,
 	}
+	ApiClientAuthTokenError = &AppError{
+		Message:  "Failed to obtain OAuth2 access token",
+		Code:     "API_CLIENT_AUTH_TOKEN_ERROR",
+		HTTPCode: http.StatusUnauthorized,
+	}
+	ApiClientPostUsersPostError = &AppError{
+		Message:  "Failed to post batch of users to API",
+		Code:     "API_CLIENT_POST_USERS_POST_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ApiClientPostUsersPayloadTooLargeError = &AppError{
+		Message:  "User payload does not fit in a batch request",
+		Code:     "API_CLIENT_POST_USERS_PAYLOAD_TOO_LARGE_ERROR",
+		HTTPCode: http.StatusRequestEntityTooLarge,
+	}
+	ApiClientPostUsersResultError = &AppError{
+		Message:  "Failed to read per-item results of batch post",
+		Code:     "API_CLIENT_POST_USERS_RESULT_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ApiClientPostUsersItemError = &AppError{
+		Message:  "Batch endpoint rejected user",
+		Code:     "API_CLIENT_POST_USERS_ITEM_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ApiClientDeleteUserError = &AppError{
+		Message:  "Failed to delete user from API",
+		Code:     "API_CLIENT_DELETE_USER_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ApiClientDeleteUserURLError = &AppError{
+		Message:  "Delete users URL is not configured",
+		Code:     "API_CLIENT_DELETE_USER_URL_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ApiClientPingError = &AppError{
+		Message:  "Users API is not reachable",
+		Code:     "API_CLIENT_PING_ERROR",
+		HTTPCode: http.StatusServiceUnavailable,
+	}
+	ApiClientLookupError = &AppError{
+		Message:  "Failed to look up data from API",
+		Code:     "API_CLIENT_LOOKUP_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
 )
@@ -0,0 +1,26 @@
+package apperrors
+
+import "net/http"
+
+var (
+	DeadLetterOpenError = &AppError{
+		Message:  "Failed to open dead-letter file",
+		Code:     "DEAD_LETTER_OPEN_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	DeadLetterWriteError = &AppError{
+		Message:  "Failed to write dead-letter entry",
+		Code:     "DEAD_LETTER_WRITE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	DeadLetterReadError = &AppError{
+		Message:  "Failed to read dead-letter file",
+		Code:     "DEAD_LETTER_READ_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	DeadLetterCloseError = &AppError{
+		Message:  "Failed to close dead-letter file",
+		Code:     "DEAD_LETTER_CLOSE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+)
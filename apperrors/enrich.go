@@ -0,0 +1,26 @@
+package apperrors
+
+import "net/http"
+
+var (
+	EnrichConfigError = &AppError{
+		Message:  "Invalid enricher configuration",
+		Code:     "ENRICH_CONFIG_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	EnrichLoadError = &AppError{
+		Message:  "Failed to load enrichment reference data",
+		Code:     "ENRICH_LOAD_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	EnrichUserError = &AppError{
+		Message:  "Failed to enrich user",
+		Code:     "ENRICH_USER_ERROR",
+		HTTPCode: http.StatusUnprocessableEntity,
+	}
+	EnrichNotFoundError = &AppError{
+		Message:  "No reference data found for user",
+		Code:     "ENRICH_NOT_FOUND_ERROR",
+		HTTPCode: http.StatusNotFound,
+	}
+)
@@ -0,0 +1,11 @@
+package apperrors
+
+import "net/http"
+
+var (
+	FilterParseError = &AppError{
+		Message:  "Failed to parse filter rules",
+		Code:     "FILTER_PARSE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+)
@@ -0,0 +1,26 @@
+package apperrors
+
+import "net/http"
+
+var (
+	LedgerOpenError = &AppError{
+		Message:  "Failed to open dispatch ledger",
+		Code:     "LEDGER_OPEN_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	LedgerReadError = &AppError{
+		Message:  "Failed to read dispatch ledger",
+		Code:     "LEDGER_READ_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	LedgerWriteError = &AppError{
+		Message:  "Failed to write dispatch ledger record",
+		Code:     "LEDGER_WRITE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	LedgerCloseError = &AppError{
+		Message:  "Failed to close dispatch ledger",
+		Code:     "LEDGER_CLOSE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+)
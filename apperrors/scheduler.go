@@ -0,0 +1,21 @@
+package apperrors
+
+import "net/http"
+
+var (
+	SchedulerInvalidScheduleError = &AppError{
+		Message:  "Invalid dispatch schedule",
+		Code:     "SCHEDULER_INVALID_SCHEDULE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	SchedulerRunError = &AppError{
+		Message:  "Scheduled dispatch cycle failed",
+		Code:     "SCHEDULER_RUN_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	SchedulerStatusWriteError = &AppError{
+		Message:  "Failed to write scheduler status",
+		Code:     "SCHEDULER_STATUS_WRITE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+)
@@ -0,0 +1,16 @@
+package apperrors
+
+import "net/http"
+
+var (
+	SchemaLoadError = &AppError{
+		Message:  "Failed to load JSON schema",
+		Code:     "SCHEMA_LOAD_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	SchemaValidationError = &AppError{
+		Message:  "Document does not match the JSON schema",
+		Code:     "SCHEMA_VALIDATION_ERROR",
+		HTTPCode: http.StatusUnprocessableEntity,
+	}
+)
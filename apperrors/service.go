@@ -33,4 +33,54 @@ var (
 		Code:     "SERVICE_DISPATCHER_SKIPPING_USER_EMAIL_WITH_SPECIAL_POSTFIX",
 		HTTPCode: http.StatusOK,
 	}
+	ServiceDispatcherWriteReportError = &AppError{
+		Message:  "Failed to write dispatcher run report",
+		Code:     "SERVICE_DISPATCHER_WRITE_REPORT_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ServiceDispatcherDeadLetterError = &AppError{
+		Message:  "Failed to dead-letter user in dispatcher service",
+		Code:     "SERVICE_DISPATCHER_DEAD_LETTER_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ServiceDispatcherLedgerError = &AppError{
+		Message:  "Failed to record delivered user in dispatch ledger",
+		Code:     "SERVICE_DISPATCHER_LEDGER_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ServiceDispatcherSnapshotError = &AppError{
+		Message:  "Failed to save user snapshot",
+		Code:     "SERVICE_DISPATCHER_SNAPSHOT_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ServiceDispatcherDeleteUserError = &AppError{
+		Message:  "Failed to delete user",
+		Code:     "SERVICE_DISPATCHER_DELETE_USER_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ServiceDispatcherEnrichError = &AppError{
+		Message:  "Failed to enrich user",
+		Code:     "SERVICE_DISPATCHER_ENRICH_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ServiceDispatcherReplayError = &AppError{
+		Message:  "Failed to replay dead-lettered users in dispatcher service",
+		Code:     "SERVICE_DISPATCHER_REPLAY_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ServiceDispatcherCanceledError = &AppError{
+		Message:  "Dispatcher run was cancelled before all users were dispatched",
+		Code:     "SERVICE_DISPATCHER_CANCELED_ERROR",
+		HTTPCode: http.StatusServiceUnavailable,
+	}
+	ServiceDispatcherCheckpointError = &AppError{
+		Message:  "Failed to save dispatch checkpoint in dispatcher service",
+		Code:     "SERVICE_DISPATCHER_CHECKPOINT_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	ServiceDispatcherResumeError = &AppError{
+		Message:  "Failed to resume dispatch in dispatcher service",
+		Code:     "SERVICE_DISPATCHER_RESUME_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
 )
@@ -0,0 +1,16 @@
+package apperrors
+
+import "net/http"
+
+var (
+	SnapshotReadError = &AppError{
+		Message:  "Failed to read user snapshot",
+		Code:     "SNAPSHOT_READ_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	SnapshotWriteError = &AppError{
+		Message:  "Failed to write user snapshot",
+		Code:     "SNAPSHOT_WRITE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+)
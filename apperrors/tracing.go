@@ -0,0 +1,11 @@
+package apperrors
+
+import "net/http"
+
+var (
+	TracingExporterError = &AppError{
+		Message:  "Failed to create trace exporter",
+		Code:     "TRACING_EXPORTER_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+)
@@ -0,0 +1,21 @@
+package apperrors
+
+import "net/http"
+
+var (
+	TransformLoadError = &AppError{
+		Message:  "Failed to load transformation spec",
+		Code:     "TRANSFORM_LOAD_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	TransformInvalidRuleError = &AppError{
+		Message:  "Invalid transformation rule",
+		Code:     "TRANSFORM_INVALID_RULE_ERROR",
+		HTTPCode: http.StatusInternalServerError,
+	}
+	TransformApplyError = &AppError{
+		Message:  "Failed to transform user",
+		Code:     "TRANSFORM_APPLY_ERROR",
+		HTTPCode: http.StatusUnprocessableEntity,
+	}
+)
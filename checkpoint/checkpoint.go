@@ -0,0 +1,125 @@
+// Package checkpoint persists how far a dispatch run got through the source,
+// so an interrupted run can resume instead of starting over.
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+// Checkpoint marks the position in the source up to which every user was
+// acknowledged, that is reached an outcome in the run report.
+type Checkpoint struct {
+	// Source identifies the source settings the checkpoint was taken with.
+	// A checkpoint is only valid for the same source.
+	Source string `json:"source"`
+	// PageURL and Page locate the source page holding the last acknowledged
+	// user, and Index is the number of users acknowledged on that page.
+	PageURL string `json:"page_url"`
+	Page    int    `json:"page"`
+	Index   int    `json:"index"`
+	// Fingerprint is the Fingerprint of the acknowledged users of the page,
+	// checked on resume to make sure the source did not change under it.
+	Fingerprint string `json:"fingerprint"`
+	// Acknowledged counts the users acknowledged since the run started,
+	// including the runs it resumed.
+	Acknowledged int       `json:"acknowledged"`
+	SavedAt      time.Time `json:"saved_at"`
+}
+
+// Store reads and writes the checkpoint file at a path.
+type Store struct {
+	path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Load returns the stored checkpoint, or nil when there is none.
+func (s *Store) Load() (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apperrors.CheckpointReadError.AppendMessage(err)
+	}
+
+	var stored Checkpoint
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, apperrors.CheckpointReadError.AppendMessage(err)
+	}
+	return &stored, nil
+}
+
+// Save replaces the stored checkpoint atomically, so a crash while saving
+// leaves the previous one in place.
+func (s *Store) Save(checkpoint Checkpoint) error {
+	checkpoint.SavedAt = time.Now().UTC()
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return apperrors.CheckpointWriteError.AppendMessage(err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return apperrors.CheckpointWriteError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		//nolint:errcheck
+		tmp.Close()
+		return apperrors.CheckpointWriteError.AppendMessage(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return apperrors.CheckpointWriteError.AppendMessage(err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return apperrors.CheckpointWriteError.AppendMessage(err)
+	}
+
+	return nil
+}
+
+// Clear removes the stored checkpoint once a run completed.
+func (s *Store) Clear() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return apperrors.CheckpointWriteError.AppendMessage(err)
+	}
+	return nil
+}
+
+// Fingerprint accumulates the content of a sequence of users, in order. Its
+// zero value is ready to use.
+type Fingerprint struct {
+	sum hash.Hash
+}
+
+// Add appends user to the fingerprinted sequence.
+func (f *Fingerprint) Add(user model.User) {
+	if f.sum == nil {
+		f.sum = sha256.New()
+	}
+	f.sum.Write([]byte(user.ContentHash()))
+}
+
+// String returns the hex encoded fingerprint of the users added so far.
+func (f *Fingerprint) String() string {
+	if f.sum == nil {
+		f.sum = sha256.New()
+	}
+	return hex.EncodeToString(f.sum.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "checkpoint.json"))
+
+	stored, err := store.Load()
+	if err != nil || stored != nil {
+		t.Fatalf("expected no checkpoint before the first save, got %+v, %v", stored, err)
+	}
+
+	saved := Checkpoint{Source: "source", PageURL: "https://example.com/users?page=2", Page: 2, Index: 3, Fingerprint: "abc", Acknowledged: 103}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored, err = store.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.SavedAt.IsZero() {
+		t.Error("expected the save time to be recorded")
+	}
+	stored.SavedAt = saved.SavedAt
+	if *stored != saved {
+		t.Errorf("expected %+v, got %+v", saved, *stored)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Clear(); err != nil {
+		t.Fatalf("unexpected error clearing twice: %v", err)
+	}
+	if stored, err := store.Load(); err != nil || stored != nil {
+		t.Errorf("expected no checkpoint after clearing, got %+v, %v", stored, err)
+	}
+}
+
+func TestStore_LoadCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(path).Load(); !apperrors.Is(err, apperrors.CheckpointReadError) {
+		t.Errorf("expected a read error, got %v", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	alice := model.User{Name: "Alice", Email: "alice@example.com"}
+	bob := model.User{Name: "Bob", Email: "bob@example.com"}
+
+	fingerprint := func(users ...model.User) string {
+		var f Fingerprint
+		for _, user := range users {
+			f.Add(user)
+		}
+		return f.String()
+	}
+
+	if fingerprint(alice, bob) != fingerprint(alice, bob) {
+		t.Error("expected the same users to share a fingerprint")
+	}
+	if fingerprint(alice, bob) == fingerprint(bob, alice) {
+		t.Error("expected the order of users to change the fingerprint")
+	}
+	if fingerprint(alice) == fingerprint(alice, bob) || fingerprint() == fingerprint(alice) {
+		t.Error("expected more users to change the fingerprint")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"data-enricher-dispatcher/apperrors"
 	"data-enricher-dispatcher/config"
@@ -23,26 +24,62 @@ const (
 
 type APIClient interface {
 	GetUsers(ctx context.Context) ([]model.User, error)
+	StreamUsers(ctx context.Context) (UserIterator, error)
 	PostUser(ctx context.Context, user model.User) error
+	// PostUsers posts users in as few requests as the client allows and
+	// returns one error per user, nil for the ones that were accepted.
+	PostUsers(ctx context.Context, users []model.User) []error
+	// DeleteUser removes a user that no longer exists in the source.
+	DeleteUser(ctx context.Context, user model.User) error
 }
+
+// Stats are cumulative counters kept by a client over its lifetime.
+type Stats struct {
+	// RateLimitWait is the total time requests waited on the rate limiter.
+	RateLimitWait time.Duration
+}
+
+// StatsReporter is implemented by clients that keep Stats.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// Pinger is implemented by clients that can check whether the source is
+// reachable without fetching users.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 type apiClient struct {
-	client      *http.Client
-	getUsersUrl string
-	postUserUrl string
+	client       *http.Client
+	getUsersUrl  string
+	postUserUrl  string
+	deleteUrl    string
+	getUsersAuth Authenticator
+	postUserAuth Authenticator
 }
 
 func NewAPIClient(cfg *config.Config) APIClient {
+	httpClient := &http.Client{}
+
 	return &apiClient{
-		client:      &http.Client{},
-		getUsersUrl: cfg.GetUsersURL,
-		postUserUrl: cfg.PostUsersURL,
+		client:       httpClient,
+		getUsersUrl:  cfg.GetUsersURL,
+		postUserUrl:  cfg.PostUsersURL,
+		deleteUrl:    cfg.DeleteUsersURL,
+		getUsersAuth: NewAuthenticator(cfg.GetUsersAuth, httpClient),
+		postUserAuth: NewAuthenticator(cfg.PostUsersAuth, httpClient),
 	}
 }
 
 func (c *apiClient) GetUsers(ctx context.Context) ([]model.User, error) {
 	var users []model.User
 	for i := 0; i < defaultAttempts; i++ {
-		resp, err := c.client.Get(c.getUsersUrl)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.getUsersUrl, nil)
+		if err != nil {
+			return nil, apperrors.ApiClientGetUsersRequestError.AppendMessage(err)
+		}
+		resp, err := doWithAuth(c.client, c.getUsersAuth, req)
 		if err != nil {
 			return nil, apperrors.ApiClientGetUsersGetError.AppendMessage(err)
 		}
@@ -83,6 +120,14 @@ func (c *apiClient) GetUsers(ctx context.Context) ([]model.User, error) {
 	return nil, apperrors.ApiClientGetUsersAttemptsExceededError.AppendMessage(fmt.Errorf(failedGetUsersError, defaultAttempts))
 }
 
+func (c *apiClient) StreamUsers(ctx context.Context) (UserIterator, error) {
+	users, err := c.GetUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewSliceIterator(users), nil
+}
+
 func (c *apiClient) PostUser(ctx context.Context, user model.User) error {
 	if !user.IsValid() {
 		return apperrors.ApiClientPostUserIsValidError.AppendMessage(fmt.Errorf("invalid user: %v", user))
@@ -92,7 +137,12 @@ func (c *apiClient) PostUser(ctx context.Context, user model.User) error {
 		if err != nil {
 			return apperrors.ApiClientPostUserMarshalError.AppendMessage(err)
 		}
-		resp, err := c.client.Post(c.postUserUrl, "application/json", bytes.NewBuffer(userData))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.postUserUrl, bytes.NewReader(userData))
+		if err != nil {
+			return apperrors.ApiClientPostUserPostError.AppendMessage(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := doWithAuth(c.client, c.postUserAuth, req)
 		if err != nil {
 			return apperrors.ApiClientPostUserPostError.AppendMessage(err)
 		}
@@ -116,3 +166,37 @@ func (c *apiClient) PostUser(ctx context.Context, user model.User) error {
 	}
 	return nil
 }
+
+func (c *apiClient) PostUsers(ctx context.Context, users []model.User) []error {
+	errs := make([]error, len(users))
+	for i, user := range users {
+		errs[i] = c.PostUser(ctx, user)
+	}
+	return errs
+}
+
+func (c *apiClient) DeleteUser(ctx context.Context, user model.User) error {
+	targetURL, err := deleteUserURL(c.deleteUrl, user)
+	if err != nil {
+		return err
+	}
+	userData, err := json.Marshal(user)
+	if err != nil {
+		return apperrors.ApiClientPostUserMarshalError.AppendMessage(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, targetURL, bytes.NewReader(userData))
+	if err != nil {
+		return apperrors.ApiClientDeleteUserError.AppendMessage(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := doWithAuth(c.client, c.postUserAuth, req)
+	if err != nil {
+		return apperrors.ApiClientDeleteUserError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer resp.Body.Close()
+	if !isSuccess(resp.StatusCode) {
+		return apperrors.ApiClientDeleteUserError.AppendMessage(fmt.Errorf(unexpectedStatusCodeError, resp.StatusCode))
+	}
+	return nil
+}
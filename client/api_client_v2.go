@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -14,72 +15,146 @@ import (
 )
 
 const (
-	defaultWaitTime     = 2 * time.Second
 	defaultTimeout      = 10 * time.Second
-	failedRetryMessage  = "failed to make request after %d attempts"
 	emptyTargetURLError = "target URL cannot be empty"
 	invalidUserError    = "invalid user data: %v"
+
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	pageError                     = "%w, page: %d"
+	unexpectedStatusCodePageError = "unexpected status code: %d, page: %d, attempts: %d"
+	maxPagesExceededError         = "stopped after %d pages"
 )
 
 type apiClientV2 struct {
-	client      *http.Client
-	getUsersUrl string
-	postUserUrl string
+	client            *http.Client
+	getUsersUrl       string
+	postUserUrl       string
+	postUsersBatchUrl string
+	deleteUserUrl     string
+	batchSize         int
+	batchMaxBytes     int
+	getUsersAuth      Authenticator
+	postUserAuth      Authenticator
+	retryPolicy       *RetryPolicy
+	postLimiter       *RateLimiter
+	paginator         paginator
+	maxPages          int
+	payloads          PayloadMarshaler
+	records           RecordValidator
 }
 
-func NewAPIClientV2(cfg *config.Config) APIClient {
-	return &apiClientV2{
-		client:      &http.Client{},
-		getUsersUrl: cfg.GetUsersURL,
-		postUserUrl: cfg.PostUsersURL,
-	}
+// PayloadMarshaler turns a user into the JSON payload sent to the sink.
+type PayloadMarshaler interface {
+	Marshal(user model.User) ([]byte, error)
 }
 
-func (c *apiClientV2) GetUsers(ctx context.Context) ([]model.User, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.getUsersUrl, nil)
-	if err != nil {
-		return nil, apperrors.ApiClientGetUsersRequestError.AppendMessage(err)
-	}
+// JSONMarshaler sends users in their plain JSON form. It is the default.
+type JSONMarshaler struct{}
+
+func (JSONMarshaler) Marshal(user model.User) ([]byte, error) {
+	return json.Marshal(user)
+}
+
+// RecordValidator checks a raw source record before it is decoded into a
+// user, returning why it is rejected.
+type RecordValidator interface {
+	Validate(record []byte) error
+}
 
-	resp, err := c.client.Do(req)
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf(unexpectedStatusCodeError, resp.StatusCode)
-		return nil, apperrors.ApiClientGetUsersStatusCodeNotOkError.AppendMessage(err)
+// Option customizes a client created by NewAPIClientV2.
+type Option func(*apiClientV2)
+
+// WithPayloadMarshaler sends the payloads built by m instead of the plain
+// JSON form of each user.
+func WithPayloadMarshaler(m PayloadMarshaler) Option {
+	return func(c *apiClientV2) {
+		c.payloads = m
 	}
-	if err != nil {
-		return nil, apperrors.ApiClientGetUsersGetError.AppendMessage(err)
+}
+
+// WithRecordValidator checks every source record with v. Rejected records
+// are still handed out by the iterator, flagged through Rejected.
+func WithRecordValidator(v RecordValidator) Option {
+	return func(c *apiClientV2) {
+		c.records = v
 	}
+}
+
+func NewAPIClientV2(cfg *config.Config, opts ...Option) APIClient {
+	// Streamed bodies are read as fast as users are dispatched, so only the
+	// wait for response headers is bounded instead of the whole exchange.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = defaultTimeout
+
+	httpClient := &http.Client{Transport: transport}
+
+	c := &apiClientV2{
+		client:            httpClient,
+		getUsersUrl:       cfg.GetUsersURL,
+		postUserUrl:       cfg.PostUsersURL,
+		postUsersBatchUrl: valueOrDefault(cfg.PostUsersBatchURL, cfg.PostUsersURL),
+		deleteUserUrl:     cfg.DeleteUsersURL,
+		batchSize:         intOrDefault(cfg.PostBatchSize, defaultBatchSize),
+		batchMaxBytes:     intOrDefault(cfg.PostBatchMaxBytes, defaultBatchMaxBytes),
+		getUsersAuth:      NewAuthenticator(cfg.GetUsersAuth, httpClient),
+		postUserAuth:      NewAuthenticator(cfg.PostUsersAuth, httpClient),
+		retryPolicy:       NewRetryPolicy(cfg),
+		postLimiter:       NewRateLimiter(cfg.PostRateLimit, cfg.PostRateBurst),
+		paginator:         newPaginator(cfg),
+		maxPages:          intOrDefault(cfg.GetUsersMaxPages, defaultMaxPages),
+		payloads:          JSONMarshaler{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *apiClientV2) Stats() Stats {
+	return Stats{RateLimitWait: c.postLimiter.Waited()}
+}
 
-	var users []model.User
-	err = json.NewDecoder(resp.Body).Decode(&users)
+// GetUsers materializes the whole user set, leaving out rejected records.
+// Prefer StreamUsers for large sources.
+func (c *apiClientV2) GetUsers(ctx context.Context) (users []model.User, err error) {
+	it, err := c.StreamUsers(ctx)
 	if err != nil {
-		return nil, apperrors.ApiClientGetUsersUnmarshalError.AppendMessage(err)
+		return nil, err
 	}
 	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			err = apperrors.ApiClientGetUsersCloseBodyError.AppendMessage(closeErr)
-			return
+		if closeErr := it.Close(); closeErr != nil && err == nil {
+			err = closeErr
 		}
 	}()
-	if len(users) == 0 {
-		return nil, apperrors.ApiClientGetUsersEmptyResponseError.AppendMessage(fmt.Errorf(emptyResponseError, 0))
+
+	for it.Next() {
+		if it.Rejected() == nil {
+			users = append(users, it.User())
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
 	}
 
 	return users, nil
 }
 
+// PostUser posts a single user with an Idempotency-Key header set to the
+// user's content hash, so the sink can drop resends of the same payload.
 func (c *apiClientV2) PostUser(ctx context.Context, user model.User) error {
+	ctx = withOperation(ctx, OperationPostUser)
 	if !user.IsValid() {
 		return apperrors.ApiClientPostUserIsValidError.AppendMessage(fmt.Errorf(invalidUserError, user))
 	}
-	userData, err := json.Marshal(user)
+	userData, err := c.payloads.Marshal(user)
 	if err != nil {
 		return apperrors.ApiClientPostUserMarshalError.AppendMessage(err)
 	}
-	userDataBuffer := bytes.NewBuffer(userData)
-	resp, err := makePostRequestWithRetry(ctx, http.MethodPost, c.postUserUrl, "application/json", defaultAttempts, userDataBuffer, defaultTimeout)
+	header := http.Header{idempotencyKeyHeader: {user.ContentHash()}}
+	resp, err := makePostRequestWithRetry(ctx, c.client, c.postUserAuth, c.retryPolicy, c.postLimiter, http.MethodPost, c.postUserUrl, "application/json", header, userData, defaultTimeout)
 	if err != nil {
-		return apperrors.ApiClientPostUserPostError.AppendMessage(err)
+		return wrapDeliveryError(apperrors.ApiClientPostUserPostError, err)
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
@@ -91,29 +166,37 @@ func (c *apiClientV2) PostUser(ctx context.Context, user model.User) error {
 	return nil
 }
 
-func makePostRequestWithRetry(ctx context.Context, method, targetURL, contentType string, attempts int, body *bytes.Buffer, timeout time.Duration) (*http.Response, error) {
-	if attempts <= 0 {
-		attempts = defaultAttempts
-	}
-	for i := 0; i < attempts; i++ {
-		resp, err := makePostRequestWithContext(ctx, method, targetURL, contentType, body, timeout)
-		if err != nil {
-			return nil, apperrors.ApiClientMakePostRequestWithRetryMakeRequestError.AppendMessage(err)
+// makePostRequestWithRetry sends body to targetURL, retrying according to
+// policy. Any non-2xx final response is turned into a *DeliveryError.
+// Every attempt first waits on limiter, so retries count against the rate
+// limit as well.
+func makePostRequestWithRetry(ctx context.Context, client *http.Client, auth Authenticator, policy *RetryPolicy, limiter *RateLimiter, method, targetURL, contentType string, header http.Header, body []byte, timeout time.Duration) (*http.Response, error) {
+	resp, attempts, err := policy.do(ctx, func() (*http.Response, error) {
+		if _, err := limiter.Wait(ctx); err != nil {
+			return nil, err
 		}
-		if resp.StatusCode != http.StatusOK {
-			time.Sleep(defaultWaitTime) // Wait before retrying
-			err = fmt.Errorf(unexpectedStatusCodeAttemtsError, resp.StatusCode, i+1)
-			if i == defaultAttempts-1 {
-				return nil, apperrors.ApiClientMakePostRequestWithRetryStatusCodeNotOkError.AppendMessage(err)
-			}
-			continue
+		return makePostRequestWithContext(ctx, client, auth, method, targetURL, contentType, header, body, timeout)
+	})
+	if err != nil {
+		return nil, wrapDeliveryError(apperrors.ApiClientMakePostRequestWithRetryMakeRequestError, err)
+	}
+	if !isSuccess(resp.StatusCode) {
+		//nolint:errcheck
+		resp.Body.Close()
+		err = fmt.Errorf(unexpectedStatusCodeAttemtsError, resp.StatusCode, attempts)
+		return nil, &DeliveryError{
+			Err:        apperrors.ApiClientMakePostRequestWithRetryStatusCodeNotOkError.AppendMessage(err),
+			StatusCode: resp.StatusCode,
+			Attempts:   attempts,
 		}
-		return resp, nil
 	}
-	return nil, apperrors.ApiClientMakePostRequestWithRetryAttemptsExceededError.AppendMessage(fmt.Errorf(failedRetryMessage, attempts))
+	return resp, nil
 }
 
-func makePostRequestWithContext(ctx context.Context, method, targetURL, contentType string, body *bytes.Buffer, timeout time.Duration) (*http.Response, error) {
+// makePostRequestWithContext sends body to targetURL with credentials from
+// auth and the extra header values. body is read from a fresh reader on every
+// call, so the same slice can be passed again when retrying.
+func makePostRequestWithContext(ctx context.Context, client *http.Client, auth Authenticator, method, targetURL, contentType string, header http.Header, body []byte, timeout time.Duration) (*http.Response, error) {
 	if timeout <= 0 {
 		timeout = defaultTimeout // Use default timeout if not specified
 	}
@@ -127,18 +210,44 @@ func makePostRequestWithContext(ctx context.Context, method, targetURL, contentT
 		return nil, apperrors.ApiClientMakeRequestWithContextTargetURLError.AppendMessage(emptyTargetURLError)
 	}
 	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, method, targetURL, body)
+	if client == nil {
+		client = &http.Client{}
+	}
+	if auth == nil {
+		auth = noAuth{}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, bytes.NewReader(body))
 	if err != nil {
+		cancel()
 		return nil, apperrors.ApiClientMakeRequestWithContextNewRequestWithContextError.AppendMessage(err)
 	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doWithAuth(client, auth, req)
 	if err != nil {
+		cancel()
 		return nil, apperrors.ApiClientMakeRequestWithContextDoError.AppendMessage(err)
 	}
+	// The timeout also covers reading the body, so it is only released once
+	// the caller closes it.
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
 
 	return resp, nil
 }
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -3,8 +3,12 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"data-enricher-dispatcher/apperrors"
 	"data-enricher-dispatcher/config"
@@ -36,7 +40,7 @@ func TestApiClientV2_GetUsers(t *testing.T) {
 			mockUsers:  []model.User{{Name: "John Doe", Email: "email2@email.com"}},
 			statusCode: http.StatusInternalServerError,
 			expectedErr: apperrors.ApiClientGetUsersStatusCodeNotOkError.AppendMessage(
-				"unexpected status code: 500").Error(),
+				"unexpected status code: 500, page: 1, attempts: 3").Error(),
 		},
 		{
 			name:        "invalid json response",
@@ -52,7 +56,8 @@ func TestApiClientV2_GetUsers(t *testing.T) {
 			defer server.Close()
 
 			client := NewAPIClientV2(&config.Config{
-				GetUsersURL: server.URL,
+				GetUsersURL:          server.URL,
+				RetryInitialInterval: time.Millisecond,
 			})
 			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 			defer cancel()
@@ -136,3 +141,44 @@ func Test_apiClientV2_PostUser(t *testing.T) {
 		})
 	}
 }
+
+func TestApiClientV2_PostUserIdempotencyKey(t *testing.T) {
+	user := model.User{Name: "John Doe", Email: "john@email.com"}
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Idempotency-Key")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL})
+	if err := client.PostUser(context.Background(), user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != user.ContentHash() {
+		t.Errorf("expected Idempotency-Key %q, got %q", user.ContentHash(), got)
+	}
+}
+
+type upperNameMarshaler struct{}
+
+func (upperNameMarshaler) Marshal(user model.User) ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"full_name":%q}`, strings.ToUpper(user.Name))), nil
+}
+
+func TestApiClientV2_PostUserPayloadMarshaler(t *testing.T) {
+	var got []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL}, WithPayloadMarshaler(upperNameMarshaler{}))
+	if err := client.PostUser(context.Background(), model.User{Name: "John Doe", Email: "john@email.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != `{"full_name":"JOHN DOE"}` {
+		t.Errorf("expected the marshaled payload to be posted, got %s", got)
+	}
+}
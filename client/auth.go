@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+)
+
+const (
+	AuthNone   = "none"
+	AuthBearer = "bearer"
+	AuthAPIKey = "api_key"
+	AuthBasic  = "basic"
+	AuthOAuth2 = "oauth2"
+
+	defaultAPIKeyHeader = "X-API-Key"
+	tokenExpirySkew     = 30 * time.Second
+	emptyAccessToken    = "token endpoint returned no access_token"
+)
+
+// Authenticator adds credentials to outgoing requests.
+type Authenticator interface {
+	// Apply sets the credentials on req.
+	Apply(ctx context.Context, req *http.Request) error
+	// Refresh drops cached credentials after the server answered 401 and
+	// reports whether resending the request may succeed.
+	Refresh(ctx context.Context) bool
+}
+
+// NewAuthenticator builds the Authenticator described by cfg. httpClient is
+// used to reach the token endpoint of OAuth2 client credentials flows.
+func NewAuthenticator(cfg config.AuthConfig, httpClient *http.Client) Authenticator {
+	switch strings.ToLower(cfg.Type) {
+	case AuthBearer:
+		return &bearerAuth{token: cfg.Token}
+	case AuthAPIKey:
+		return &apiKeyAuth{header: valueOrDefault(cfg.APIKeyHeader, defaultAPIKeyHeader), key: cfg.APIKey}
+	case AuthBasic:
+		return &basicAuth{username: cfg.Username, password: cfg.Password}
+	case AuthOAuth2:
+		return &oauth2Auth{
+			client:       httpClient,
+			tokenURL:     cfg.TokenURL,
+			clientID:     cfg.ClientID,
+			clientSecret: cfg.ClientSecret,
+			scopes:       cfg.Scopes,
+		}
+	default:
+		return noAuth{}
+	}
+}
+
+type noAuth struct{}
+
+func (noAuth) Apply(context.Context, *http.Request) error {
+	return nil
+}
+
+func (noAuth) Refresh(context.Context) bool {
+	return false
+}
+
+type bearerAuth struct {
+	token string
+}
+
+func (a *bearerAuth) Apply(_ context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (a *bearerAuth) Refresh(context.Context) bool {
+	return false
+}
+
+type apiKeyAuth struct {
+	header string
+	key    string
+}
+
+func (a *apiKeyAuth) Apply(_ context.Context, req *http.Request) error {
+	req.Header.Set(a.header, a.key)
+	return nil
+}
+
+func (a *apiKeyAuth) Refresh(context.Context) bool {
+	return false
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func (a *basicAuth) Apply(_ context.Context, req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+func (a *basicAuth) Refresh(context.Context) bool {
+	return false
+}
+
+// oauth2Auth implements the OAuth2 client credentials grant, caching the
+// access token until shortly before it expires.
+type oauth2Auth struct {
+	client       *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (a *oauth2Auth) Apply(ctx context.Context, req *http.Request) error {
+	token, err := a.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *oauth2Auth) Refresh(context.Context) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+	return true
+}
+
+func (a *oauth2Auth) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && (a.expiresAt.IsZero() || time.Now().Before(a.expiresAt)) {
+		return a.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", apperrors.ApiClientAuthTokenError.AppendMessage(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", apperrors.ApiClientAuthTokenError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", apperrors.ApiClientAuthTokenError.AppendMessage(fmt.Errorf(unexpectedStatusCodeError, resp.StatusCode))
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", apperrors.ApiClientAuthTokenError.AppendMessage(err)
+	}
+	if token.AccessToken == "" {
+		return "", apperrors.ApiClientAuthTokenError.AppendMessage(emptyAccessToken)
+	}
+
+	a.token = token.AccessToken
+	a.expiresAt = time.Time{}
+	if token.ExpiresIn > 0 {
+		a.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpirySkew)
+	}
+
+	return a.token, nil
+}
+
+// doWithAuth sends req with credentials from auth. When the server answers
+// 401 and auth was able to refresh its credentials the request is sent once
+// more with the new ones.
+func doWithAuth(httpClient *http.Client, auth Authenticator, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := auth.Apply(ctx, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !auth.Refresh(ctx) {
+		return resp, err
+	}
+	//nolint:errcheck
+	resp.Body.Close()
+
+	retry := req.Clone(ctx)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if err := auth.Apply(ctx, retry); err != nil {
+		return nil, err
+	}
+
+	return httpClient.Do(retry)
+}
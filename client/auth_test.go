@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+func TestNewAuthenticator_Apply(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      config.AuthConfig
+		header   string
+		expected string
+	}{
+		{
+			name:     "none",
+			cfg:      config.AuthConfig{Type: AuthNone},
+			header:   "Authorization",
+			expected: "",
+		},
+		{
+			name:     "bearer",
+			cfg:      config.AuthConfig{Type: AuthBearer, Token: "secret"},
+			header:   "Authorization",
+			expected: "Bearer secret",
+		},
+		{
+			name:     "api key with default header",
+			cfg:      config.AuthConfig{Type: AuthAPIKey, APIKey: "key"},
+			header:   "X-API-Key",
+			expected: "key",
+		},
+		{
+			name:     "api key with custom header",
+			cfg:      config.AuthConfig{Type: AuthAPIKey, APIKeyHeader: "X-Token", APIKey: "key"},
+			header:   "X-Token",
+			expected: "key",
+		},
+		{
+			name:     "basic",
+			cfg:      config.AuthConfig{Type: AuthBasic, Username: "user", Password: "pass"},
+			header:   "Authorization",
+			expected: "Basic dXNlcjpwYXNz",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			auth := NewAuthenticator(tc.cfg, http.DefaultClient)
+			if err := auth.Apply(context.Background(), req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := req.Header.Get(tc.header); got != tc.expected {
+				t.Errorf("expected %s header %q, got %q", tc.header, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestOAuth2_RefreshesTokenOn401(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		token := "token-1"
+		if atomic.AddInt32(&issued, 1) > 1 {
+			token = "token-2"
+		}
+		//nolint:errcheck
+		json.NewEncoder(w).Encode(tokenResponse{AccessToken: token, TokenType: "Bearer", ExpiresIn: 3600})
+	}))
+	defer tokenServer.Close()
+
+	var bodies []string
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user model.User
+		//nolint:errcheck
+		json.NewDecoder(r.Body).Decode(&user)
+		bodies = append(bodies, user.Email)
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer sink.Close()
+
+	client := NewAPIClientV2(&config.Config{
+		PostUsersURL: sink.URL,
+		PostUsersAuth: config.AuthConfig{
+			Type:         AuthOAuth2,
+			TokenURL:     tokenServer.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+		},
+	})
+
+	err := client.PostUser(context.Background(), model.User{Name: "John Doe", Email: "john@email.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&issued); got != 2 {
+		t.Errorf("expected 2 tokens to be issued, got %d", got)
+	}
+	if len(bodies) != 2 || bodies[1] != "john@email.com" {
+		t.Errorf("expected the body to be resent after refresh, got %v", bodies)
+	}
+}
+
+func TestOAuth2_TokenEndpointFailure(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer tokenServer.Close()
+
+	auth := NewAuthenticator(config.AuthConfig{Type: AuthOAuth2, TokenURL: tokenServer.URL}, http.DefaultClient)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	err := auth.Apply(context.Background(), req)
+	if !apperrors.Is(err, apperrors.ApiClientAuthTokenError) {
+		t.Errorf("expected %s, got %v", apperrors.ApiClientAuthTokenError.Code, err)
+	}
+}
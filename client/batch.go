@@ -0,0 +1,155 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultBatchMaxBytes = 1 << 20
+
+	payloadTooLargeError = "user payload of %d bytes exceeds the batch limit of %d bytes"
+	itemStatusError      = "item status: %d, error: %s"
+	missingItemError     = "no result returned for item %d"
+)
+
+// batchItemResult is the outcome the bulk endpoint reports for one posted
+// user. Index refers to the position in the posted array; when it is absent
+// results are matched by their own position.
+type batchItemResult struct {
+	Index  *int   `json:"index,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchChunk is a group of users sent in one request.
+type batchChunk struct {
+	indexes []int
+	payload []byte
+}
+
+// PostUsers posts users to the bulk endpoint, splitting them into requests
+// of at most the configured batch size and payload bytes. The returned slice
+// holds one error per user, nil for the ones the endpoint accepted. An empty
+// response body is taken as every item of the request being accepted. Each
+// request carries an Idempotency-Key derived from its payload.
+func (c *apiClientV2) PostUsers(ctx context.Context, users []model.User) []error {
+	ctx = withOperation(ctx, OperationPostUsers)
+	errs := make([]error, len(users))
+	for _, chunk := range c.chunkUsers(users, errs) {
+		c.postChunk(ctx, chunk, errs)
+	}
+	return errs
+}
+
+// chunkUsers marshals every valid user and groups the payloads into chunks.
+// Users that cannot be sent at all get their error set in errs.
+func (c *apiClientV2) chunkUsers(users []model.User, errs []error) []batchChunk {
+	var chunks []batchChunk
+	current := batchChunk{payload: []byte{'['}}
+
+	flush := func() {
+		if len(current.indexes) == 0 {
+			return
+		}
+		current.payload = append(current.payload, ']')
+		chunks = append(chunks, current)
+		current = batchChunk{payload: []byte{'['}}
+	}
+
+	for i, user := range users {
+		if !user.IsValid() {
+			errs[i] = apperrors.ApiClientPostUserIsValidError.AppendMessage(fmt.Errorf(invalidUserError, user))
+			continue
+		}
+		data, err := c.payloads.Marshal(user)
+		if err != nil {
+			errs[i] = apperrors.ApiClientPostUserMarshalError.AppendMessage(err)
+			continue
+		}
+		if len(data)+2 > c.batchMaxBytes {
+			errs[i] = apperrors.ApiClientPostUsersPayloadTooLargeError.AppendMessage(fmt.Errorf(payloadTooLargeError, len(data), c.batchMaxBytes))
+			continue
+		}
+
+		if len(current.indexes) == c.batchSize || len(current.payload)+len(data)+2 > c.batchMaxBytes {
+			flush()
+		}
+		if len(current.indexes) > 0 {
+			current.payload = append(current.payload, ',')
+		}
+		current.payload = append(current.payload, data...)
+		current.indexes = append(current.indexes, i)
+	}
+	flush()
+
+	return chunks
+}
+
+func (c *apiClientV2) postChunk(ctx context.Context, chunk batchChunk, errs []error) {
+	failAll := func(err error) {
+		for _, index := range chunk.indexes {
+			errs[index] = err
+		}
+	}
+
+	sum := sha256.Sum256(chunk.payload)
+	header := http.Header{idempotencyKeyHeader: {hex.EncodeToString(sum[:])}}
+	resp, err := makePostRequestWithRetry(ctx, c.client, c.postUserAuth, c.retryPolicy, c.postLimiter, http.MethodPost, c.postUsersBatchUrl, "application/json", header, chunk.payload, defaultTimeout)
+	if err != nil {
+		failAll(wrapDeliveryError(apperrors.ApiClientPostUsersPostError, err))
+		return
+	}
+	//nolint:errcheck
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		failAll(apperrors.ApiClientPostUsersResultError.AppendMessage(err))
+		return
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return
+	}
+
+	var results []batchItemResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		failAll(apperrors.ApiClientPostUsersResultError.AppendMessage(err))
+		return
+	}
+
+	reported := make([]bool, len(chunk.indexes))
+	for position, result := range results {
+		if result.Index != nil {
+			position = *result.Index
+		}
+		if position < 0 || position >= len(chunk.indexes) {
+			continue
+		}
+		reported[position] = true
+
+		if result.Error == "" && (result.Status == 0 || isSuccess(result.Status)) {
+			continue
+		}
+		errs[chunk.indexes[position]] = &DeliveryError{
+			Err:        apperrors.ApiClientPostUsersItemError.AppendMessage(fmt.Errorf(itemStatusError, result.Status, result.Error)),
+			StatusCode: result.Status,
+			Attempts:   1,
+		}
+	}
+	for position, ok := range reported {
+		if !ok {
+			errs[chunk.indexes[position]] = apperrors.ApiClientPostUsersResultError.AppendMessage(fmt.Errorf(missingItemError, position))
+		}
+	}
+}
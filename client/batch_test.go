@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+func TestApiClientV2_PostUsers(t *testing.T) {
+	users := []model.User{
+		{Name: "User 0", Email: "user0@email.com"},
+		{Name: "", Email: "invalid@email.com"},
+		{Name: "User 2", Email: "user2@email.com"},
+		{Name: "User 3", Email: "user3@email.com"},
+	}
+
+	var mu sync.Mutex
+	var requests [][]model.User
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var posted []model.User
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, posted)
+		mu.Unlock()
+
+		results := make([]batchItemResult, 0, len(posted))
+		for i, user := range posted {
+			index := i
+			result := batchItemResult{Index: &index, Status: http.StatusCreated}
+			if user.Email == "user3@email.com" {
+				result = batchItemResult{Index: &index, Status: http.StatusConflict, Error: "already exists"}
+			}
+			results = append(results, result)
+		}
+		// Results may come back in any order when they carry an index.
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+		//nolint:errcheck
+		json.NewEncoder(w).Encode(results)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{PostUsersBatchURL: server.URL, PostBatchSize: 2})
+	errs := client.PostUsers(context.Background(), users)
+
+	if len(requests) != 2 || len(requests[0]) != 2 || len(requests[1]) != 1 {
+		t.Fatalf("expected requests of 2 and 1 users, got %v", requests)
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("expected accepted users to have no error, got %v", errs)
+	}
+	if !apperrors.Is(errs[1], apperrors.ApiClientPostUserIsValidError) {
+		t.Errorf("expected the invalid user to be rejected locally, got %v", errs[1])
+	}
+	var deliveryErr *DeliveryError
+	if !errors.As(errs[3], &deliveryErr) || deliveryErr.StatusCode != http.StatusConflict {
+		t.Errorf("expected the conflicting user to carry status 409, got %v", errs[3])
+	}
+}
+
+func TestApiClientV2_PostUsersMaxBytes(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	users := make([]model.User, 0, 4)
+	for i := 0; i < 4; i++ {
+		users = append(users, model.User{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@email.com", i)})
+	}
+	users = append(users, model.User{Name: string(make([]byte, 200)), Email: "huge@email.com"})
+	single, _ := json.Marshal(users[0])
+
+	client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL, PostBatchSize: 10, PostBatchMaxBytes: 2*len(single) + 3})
+	errs := client.PostUsers(context.Background(), users)
+
+	if requests != 2 {
+		t.Errorf("expected the payload limit to split 4 users into 2 requests, got %d", requests)
+	}
+	for i := 0; i < 4; i++ {
+		if errs[i] != nil {
+			t.Errorf("unexpected error for user %d: %v", i, errs[i])
+		}
+	}
+	if !apperrors.Is(errs[4], apperrors.ApiClientPostUsersPayloadTooLargeError) {
+		t.Errorf("expected the oversized user to be rejected, got %v", errs[4])
+	}
+}
+
+func TestApiClientV2_PostUsersRequestFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL, PostBatchSize: 10, RetryInitialInterval: time.Millisecond})
+	errs := client.PostUsers(context.Background(), []model.User{
+		{Name: "User 0", Email: "user0@email.com"},
+		{Name: "User 1", Email: "user1@email.com"},
+	})
+	for i, err := range errs {
+		if !apperrors.Is(err, apperrors.ApiClientPostUsersPostError) {
+			t.Errorf("expected user %d to fail with the request, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const deleteKeyPlaceholder = "{key}"
+
+// DeleteUser tells the sink that user no longer exists in the source. The
+// user is sent as the request body, and a "{key}" placeholder in the delete
+// URL is replaced with the user's key.
+func (c *apiClientV2) DeleteUser(ctx context.Context, user model.User) error {
+	ctx = withOperation(ctx, OperationDeleteUser)
+	targetURL, err := deleteUserURL(c.deleteUserUrl, user)
+	if err != nil {
+		return err
+	}
+	userData, err := c.payloads.Marshal(user)
+	if err != nil {
+		return apperrors.ApiClientPostUserMarshalError.AppendMessage(err)
+	}
+	resp, err := makePostRequestWithRetry(ctx, c.client, c.postUserAuth, c.retryPolicy, c.postLimiter, http.MethodDelete, targetURL, "application/json", nil, userData, defaultTimeout)
+	if err != nil {
+		return wrapDeliveryError(apperrors.ApiClientDeleteUserError, err)
+	}
+	//nolint:errcheck
+	resp.Body.Close()
+
+	return nil
+}
+
+func deleteUserURL(template string, user model.User) (string, error) {
+	if template == "" {
+		return "", apperrors.ApiClientDeleteUserURLError.AppendMessage(emptyTargetURLError)
+	}
+	return strings.ReplaceAll(template, deleteKeyPlaceholder, url.PathEscape(user.Key())), nil
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+func TestApiClientV2_DeleteUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		path       string
+		wantErr    *apperrors.AppError
+	}{
+		{name: "deleted", statusCode: http.StatusNoContent},
+		{name: "not found", statusCode: http.StatusNotFound, wantErr: apperrors.ApiClientDeleteUserError},
+		{name: "no url", wantErr: apperrors.ApiClientDeleteUserURLError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMethod, gotPath string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotMethod, gotPath = r.Method, r.URL.EscapedPath()
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{RetryInitialInterval: time.Millisecond}
+			if tt.statusCode != 0 {
+				cfg.DeleteUsersURL = server.URL + "/users/{key}"
+			}
+			client := NewAPIClientV2(cfg)
+			err := client.DeleteUser(context.Background(), model.User{Name: "John Doe", Email: "John+1@Test.com"})
+
+			if tt.wantErr != nil {
+				if !apperrors.Is(err, tt.wantErr) {
+					t.Errorf("expected %s, got %v", tt.wantErr.Code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotMethod != http.MethodDelete || gotPath != "/users/john+1@test.com" {
+				t.Errorf("unexpected request: %s %s", gotMethod, gotPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"errors"
+
+	"data-enricher-dispatcher/apperrors"
+)
+
+// DeliveryError is returned when a request was given up on. It keeps the
+// last response status and the number of attempts made alongside the
+// underlying *apperrors.AppError so callers can record them.
+type DeliveryError struct {
+	Err        error
+	StatusCode int
+	Attempts   int
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// wrapDeliveryError appends err to appError like AppendMessage does, keeping
+// the delivery details of err when it carries any.
+func wrapDeliveryError(appError *apperrors.AppError, err error) error {
+	wrapped := appError.AppendMessage(err)
+
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) {
+		return wrapped
+	}
+
+	return &DeliveryError{
+		Err:        wrapped,
+		StatusCode: deliveryErr.StatusCode,
+		Attempts:   deliveryErr.Attempts,
+	}
+}
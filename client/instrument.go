@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"data-enricher-dispatcher/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "data-enricher-dispatcher/client"
+	// operationAttribute is the span attribute holding the operation of a
+	// request.
+	operationAttribute = attribute.Key("dispatcher.operation")
+)
+
+// Operations label the requests of the client in metrics.
+const (
+	OperationGetUsers   = "get_users"
+	OperationPostUser   = "post_user"
+	OperationPostUsers  = "post_users"
+	OperationDeleteUser = "delete_user"
+	OperationPing       = "ping"
+	OperationLookup     = "lookup"
+	OperationOther      = "other"
+)
+
+type operationKey struct{}
+
+// withOperation labels the requests sent with ctx as operation.
+func withOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// operationOf returns the operation ctx was labelled with, or OperationOther.
+func operationOf(ctx context.Context) string {
+	if operation, ok := ctx.Value(operationKey{}).(string); ok {
+		return operation
+	}
+	return OperationOther
+}
+
+// metricsTransport records every request attempt that goes through next.
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics *metrics.Metrics
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation := operationOf(req.Context())
+	t.metrics.RequestStarted(operation)
+	startedAt := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	var statusCode int
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	t.metrics.RequestFinished(operation, statusCode, time.Since(startedAt))
+	return resp, err
+}
+
+// WithMetrics records the latency, status code and retries of every request
+// attempt in m, including those of token requests made for authentication.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(c *apiClientV2) {
+		next := c.client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		c.client.Transport = metricsTransport{next: next, metrics: m}
+		c.retryPolicy.metrics = m
+	}
+}
+
+// tracingTransport records a client span for every request attempt that goes
+// through next and propagates it to the server in a W3C traceparent header.
+// The span ends once the response headers arrived.
+type tracingTransport struct {
+	next   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation := operationOf(req.Context())
+	ctx, span := t.tracer.Start(req.Context(), req.Method+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+			operationAttribute.String(operation),
+		),
+	)
+	defer span.End()
+
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(ctx)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// WithTracerProvider records a span in tp for every request attempt,
+// including those of token requests made for authentication, and sends its
+// trace context along with the request.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *apiClientV2) {
+		next := c.client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		c.client.Transport = tracingTransport{next: next, tracer: tp.Tracer(tracerName)}
+	}
+}
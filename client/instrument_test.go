@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/metrics"
+	"data-enricher-dispatcher/model"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestApiClientV2_Metrics(t *testing.T) {
+	var posts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			//nolint:errcheck
+			w.Write([]byte(`[{"id":1,"name":"John Doe","email":"john@email.com"}]`))
+			return
+		}
+		if atomic.AddInt32(&posts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	m := metrics.New("test")
+	client := NewAPIClientV2(&config.Config{GetUsersURL: server.URL, PostUsersURL: server.URL, RetryMaxAttempts: 3, RetryInitialInterval: time.Millisecond}, WithMetrics(m))
+	users, err := client.GetUsers(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.PostUser(context.Background(), model.User{Name: "John Doe", Email: "john@email.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`dispatcher_http_request_duration_seconds_count{code="200",environment="test",operation="get_users"} 1`,
+		`dispatcher_http_request_duration_seconds_count{code="503",environment="test",operation="post_user"} 1`,
+		`dispatcher_http_request_duration_seconds_count{code="201",environment="test",operation="post_user"} 1`,
+		`dispatcher_http_request_retries_total{environment="test",operation="post_user"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in:\n%s", want, body)
+		}
+	}
+	if len(users) != 1 {
+		t.Errorf("expected 1 user, got %d", len(users))
+	}
+}
+
+func TestApiClientV2_Tracing(t *testing.T) {
+	var posts int32
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if atomic.AddInt32(&posts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "post")
+	client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL, RetryMaxAttempts: 3, RetryInitialInterval: time.Millisecond}, WithTracerProvider(provider))
+	if err := client.PostUser(ctx, model.User{Name: "John Doe", Email: "john@email.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected a span per attempt and the parent, got %d", len(spans))
+	}
+	for i, wantStatus := range []int{http.StatusServiceUnavailable, http.StatusCreated} {
+		span := spans[i]
+		if span.Name() != "POST post_user" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("attempt %d: unexpected span %q with parent %s", i+1, span.Name(), span.Parent().SpanID())
+		}
+		if !hasAttribute(span.Attributes(), attribute.Int("http.response.status_code", wantStatus)) {
+			t.Errorf("attempt %d: expected status %d in %v", i+1, wantStatus, span.Attributes())
+		}
+		want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+		if traceparents[i] != want {
+			t.Errorf("attempt %d: expected traceparent %s, got %s", i+1, want, traceparents[i])
+		}
+	}
+	if spans[0].Status().Code != codes.Error || spans[1].Status().Code == codes.Error {
+		t.Errorf("expected only the failed attempt to be an error, got %v and %v", spans[0].Status(), spans[1].Status())
+	}
+}
+
+func TestLookupClient_Instrumented(t *testing.T) {
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&gets, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		//nolint:errcheck
+		w.Write([]byte(`{"segment":"vip"}`))
+	}))
+	defer server.Close()
+
+	m := metrics.New("test")
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	client := NewLookupClient(&config.Config{RetryMaxAttempts: 3, RetryInitialInterval: time.Millisecond}, WithMetrics(m), WithTracerProvider(provider))
+	if _, err := client.Get(context.Background(), server.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`dispatcher_http_request_duration_seconds_count{code="503",environment="test",operation="lookup"} 1`,
+		`dispatcher_http_request_duration_seconds_count{code="200",environment="test",operation="lookup"} 1`,
+		`dispatcher_http_request_retries_total{environment="test",operation="lookup"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in:\n%s", want, body)
+		}
+	}
+	ended := spans.Ended()
+	if len(ended) != 2 || ended[0].Name() != "GET lookup" {
+		t.Errorf("expected a GET lookup span per attempt, got %d spans", len(ended))
+	}
+}
+
+func hasAttribute(attributes []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, kv := range attributes {
+		if kv == want {
+			return true
+		}
+	}
+	return false
+}
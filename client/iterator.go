@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+
+	"data-enricher-dispatcher/model"
+)
+
+// UserIterator yields users one at a time as they are read from the source.
+//
+//	for it.Next() {
+//		user := it.User()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type UserIterator interface {
+	// Next advances to the next user and reports whether there is one.
+	Next() bool
+	// User returns the user the last successful Next advanced to.
+	User() model.User
+	// Rejected returns why the source record of the current user failed
+	// record validation, or nil when it passed. The user of a rejected
+	// record is decoded on a best-effort basis and may be incomplete.
+	Rejected() error
+	// Err returns the error that stopped the iteration, if any.
+	Err() error
+	// Close releases the underlying response body.
+	Close() error
+}
+
+// Position locates a user in a paginated source.
+type Position struct {
+	// PageURL and Page identify the page holding the user.
+	PageURL string
+	Page    int
+	// Index is the zero-based index of the user within its page.
+	Index int
+}
+
+// Positioner is implemented by iterators that know where in the source the
+// current user is.
+type Positioner interface {
+	// Position returns the position of the user the last successful Next
+	// advanced to.
+	Position() Position
+}
+
+// Resumer is implemented by clients that can start streaming users at a
+// page other than the first one.
+type Resumer interface {
+	// StreamUsersFrom streams the users of the source from the first user of
+	// the page at position, ignoring its Index, onwards.
+	StreamUsersFrom(ctx context.Context, position Position) (UserIterator, error)
+}
+
+type sliceIterator struct {
+	users []model.User
+	index int
+}
+
+// NewSliceIterator adapts an already materialized slice to UserIterator.
+func NewSliceIterator(users []model.User) UserIterator {
+	return &sliceIterator{users: users, index: -1}
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.index+1 >= len(it.users) {
+		it.index = len(it.users)
+		return false
+	}
+	it.index++
+	return true
+}
+
+func (it *sliceIterator) User() model.User {
+	return it.users[it.index]
+}
+
+func (it *sliceIterator) Rejected() error {
+	return nil
+}
+
+func (it *sliceIterator) Err() error {
+	return nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+)
+
+const (
+	defaultLookupMaxBytes = 1 << 20
+
+	lookupTooLargeError = "response larger than %d bytes"
+)
+
+// LookupClient reads JSON documents from a secondary API, sharing the retry
+// policy and per-request timeout of the dispatch requests.
+type LookupClient struct {
+	client      *http.Client
+	auth        Authenticator
+	retryPolicy *RetryPolicy
+	timeout     time.Duration
+	maxBytes    int
+}
+
+// NewLookupClient creates a lookup client. opts are those of
+// NewAPIClientV2; only the ones acting on the HTTP client and the retry
+// policy, such as WithMetrics and WithTracerProvider, have an effect.
+func NewLookupClient(cfg *config.Config, opts ...Option) *LookupClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = defaultTimeout
+
+	instrumented := &apiClientV2{client: &http.Client{Transport: transport}, retryPolicy: NewRetryPolicy(cfg)}
+	for _, opt := range opts {
+		opt(instrumented)
+	}
+
+	return &LookupClient{
+		client:      instrumented.client,
+		auth:        NewAuthenticator(cfg.EnrichHTTPAuth, instrumented.client),
+		retryPolicy: instrumented.retryPolicy,
+		timeout:     durationOrDefault(cfg.EnrichHTTPTimeout, defaultTimeout),
+		maxBytes:    defaultLookupMaxBytes,
+	}
+}
+
+// Get returns the body of a GET request to targetURL. Responses with a
+// non-2xx status end in a *DeliveryError carrying the status code, so a
+// missing document can be told apart from a failing API.
+func (c *LookupClient) Get(ctx context.Context, targetURL string) ([]byte, error) {
+	ctx = withOperation(ctx, OperationLookup)
+	header := http.Header{"Accept": {"application/json"}}
+	resp, err := makePostRequestWithRetry(ctx, c.client, c.auth, c.retryPolicy, nil, http.MethodGet, targetURL, "", header, nil, c.timeout)
+	if err != nil {
+		return nil, wrapDeliveryError(apperrors.ApiClientLookupError, err)
+	}
+	//nolint:errcheck
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(c.maxBytes)+1))
+	if err != nil {
+		return nil, apperrors.ApiClientLookupError.AppendMessage(err)
+	}
+	if len(body) > c.maxBytes {
+		return nil, apperrors.ApiClientLookupError.AppendMessage(fmt.Errorf(lookupTooLargeError, c.maxBytes))
+	}
+
+	return body, nil
+}
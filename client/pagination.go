@@ -0,0 +1,192 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"data-enricher-dispatcher/config"
+)
+
+const (
+	PaginationNone   = "none"
+	PaginationPage   = "page"
+	PaginationCursor = "cursor"
+	PaginationLink   = "link"
+
+	defaultPageParam    = "page"
+	defaultLimitParam   = "limit"
+	defaultCursorParam  = "cursor"
+	defaultCursorHeader = "X-Next-Cursor"
+	defaultPageSize     = 100
+	defaultMaxPages     = 1000
+)
+
+// paginator decides which URLs GetUsers requests to walk the whole user set.
+type paginator interface {
+	// first returns the URL of the first page.
+	first(baseURL string) (string, error)
+	// next returns the URL of the page following pageURL, or an empty string
+	// once the source is exhausted. count is the number of users pageURL held.
+	next(pageURL string, header http.Header, count int) (string, error)
+}
+
+func newPaginator(cfg *config.Config) paginator {
+	switch strings.ToLower(cfg.GetUsersPagination) {
+	case PaginationPage:
+		return &pagePaginator{
+			pageParam:  valueOrDefault(cfg.GetUsersPageParam, defaultPageParam),
+			limitParam: valueOrDefault(cfg.GetUsersLimitParam, defaultLimitParam),
+			pageSize:   intOrDefault(cfg.GetUsersPageSize, defaultPageSize),
+		}
+	case PaginationCursor:
+		return &cursorPaginator{
+			cursorParam:  valueOrDefault(cfg.GetUsersCursorParam, defaultCursorParam),
+			cursorHeader: valueOrDefault(cfg.GetUsersCursorHeader, defaultCursorHeader),
+			limitParam:   valueOrDefault(cfg.GetUsersLimitParam, defaultLimitParam),
+			pageSize:     intOrDefault(cfg.GetUsersPageSize, defaultPageSize),
+		}
+	case PaginationLink:
+		return &linkPaginator{}
+	default:
+		return &singlePagePaginator{}
+	}
+}
+
+// singlePagePaginator treats the source as one unpaginated JSON array.
+type singlePagePaginator struct{}
+
+func (p *singlePagePaginator) first(baseURL string) (string, error) {
+	return baseURL, nil
+}
+
+func (p *singlePagePaginator) next(string, http.Header, int) (string, error) {
+	return "", nil
+}
+
+// pagePaginator walks page/limit query parameters until a short page is returned.
+type pagePaginator struct {
+	pageParam  string
+	limitParam string
+	pageSize   int
+}
+
+func (p *pagePaginator) first(baseURL string) (string, error) {
+	return withQuery(baseURL, map[string]string{
+		p.pageParam:  "1",
+		p.limitParam: strconv.Itoa(p.pageSize),
+	})
+}
+
+func (p *pagePaginator) next(pageURL string, _ http.Header, count int) (string, error) {
+	if count < p.pageSize {
+		return "", nil
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	page, err := strconv.Atoi(u.Query().Get(p.pageParam))
+	if err != nil {
+		return "", err
+	}
+	return withQuery(pageURL, map[string]string{p.pageParam: strconv.Itoa(page + 1)})
+}
+
+// cursorPaginator passes the opaque token returned in cursorHeader back as a
+// query parameter until the source stops returning one.
+type cursorPaginator struct {
+	cursorParam  string
+	cursorHeader string
+	limitParam   string
+	pageSize     int
+}
+
+func (p *cursorPaginator) first(baseURL string) (string, error) {
+	return withQuery(baseURL, map[string]string{p.limitParam: strconv.Itoa(p.pageSize)})
+}
+
+func (p *cursorPaginator) next(pageURL string, header http.Header, count int) (string, error) {
+	cursor := header.Get(p.cursorHeader)
+	if cursor == "" || count == 0 {
+		return "", nil
+	}
+	return withQuery(pageURL, map[string]string{p.cursorParam: cursor})
+}
+
+// linkPaginator follows RFC 5988 Link headers with rel="next".
+type linkPaginator struct{}
+
+func (p *linkPaginator) first(baseURL string) (string, error) {
+	return baseURL, nil
+}
+
+func (p *linkPaginator) next(pageURL string, header http.Header, _ int) (string, error) {
+	target := nextLink(header.Values("Link"))
+	if target == "" {
+		return "", nil
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// nextLink extracts the rel="next" target from Link header values such as
+// `<https://api/users?page=2>; rel="next", <https://api/users?page=9>; rel="last"`.
+func nextLink(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(key, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(val, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func withQuery(rawURL string, params map[string]string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func intOrDefault(value, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
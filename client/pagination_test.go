@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+func TestApiClientV2_GetUsersPaginated(t *testing.T) {
+	users := make([]model.User, 0, 5)
+	for i := 0; i < 5; i++ {
+		users = append(users, model.User{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@email.com", i)})
+	}
+
+	testCases := []struct {
+		name     string
+		cfg      config.Config
+		handler  func(w http.ResponseWriter, r *http.Request)
+		expected int
+	}{
+		{
+			name: "page and limit params",
+			cfg:  config.Config{GetUsersPagination: PaginationPage, GetUsersPageSize: 2},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+				writeUsers(t, w, pageOf(users, (page-1)*limit, limit))
+			},
+			expected: 5,
+		},
+		{
+			name: "cursor header",
+			cfg:  config.Config{GetUsersPagination: PaginationCursor, GetUsersPageSize: 2},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				offset, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+				if offset+2 < len(users) {
+					w.Header().Set("X-Next-Cursor", strconv.Itoa(offset+2))
+				}
+				writeUsers(t, w, pageOf(users, offset, 2))
+			},
+			expected: 5,
+		},
+		{
+			name: "link header",
+			cfg:  config.Config{GetUsersPagination: PaginationLink},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+				if offset+2 < len(users) {
+					w.Header().Set("Link", fmt.Sprintf(`</users?offset=%d>; rel="next", </users?offset=4>; rel="last"`, offset+2))
+				}
+				writeUsers(t, w, pageOf(users, offset, 2))
+			},
+			expected: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(tc.handler))
+			defer server.Close()
+
+			cfg := tc.cfg
+			cfg.GetUsersURL = server.URL + "/users"
+			client := NewAPIClientV2(&cfg)
+
+			got, err := client.GetUsers(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != tc.expected {
+				t.Fatalf("expected %d users, got %d", tc.expected, len(got))
+			}
+			for i := range got {
+				if !got[i].IsEqual(&users[i]) {
+					t.Errorf("expected user %v, got %v", users[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApiClientV2_GetUsersPaginatedErrors(t *testing.T) {
+	t.Run("failing page is reported", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "2" {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			writeUsers(t, w, []model.User{{Name: "A", Email: "a@email.com"}})
+		}))
+		defer server.Close()
+
+		client := NewAPIClientV2(&config.Config{GetUsersURL: server.URL, GetUsersPagination: PaginationPage, GetUsersPageSize: 1, RetryInitialInterval: time.Millisecond})
+		_, err := client.GetUsers(context.Background())
+		expected := apperrors.ApiClientGetUsersStatusCodeNotOkError.AppendMessage(
+			fmt.Errorf("unexpected status code: 502, page: 2, attempts: 3")).Error()
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %q, got %v", expected, err)
+		}
+	})
+
+	t.Run("max pages cap", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Link", `<?again=1>; rel="next"`)
+			writeUsers(t, w, []model.User{{Name: "A", Email: "a@email.com"}})
+		}))
+		defer server.Close()
+
+		client := NewAPIClientV2(&config.Config{GetUsersURL: server.URL, GetUsersPagination: PaginationLink, GetUsersMaxPages: 3})
+		_, err := client.GetUsers(context.Background())
+		if !apperrors.Is(err, apperrors.ApiClientGetUsersMaxPagesExceededError) {
+			t.Fatalf("expected max pages error, got %v", err)
+		}
+	})
+}
+
+func pageOf(users []model.User, offset, limit int) []model.User {
+	if offset >= len(users) {
+		return []model.User{}
+	}
+	end := offset + limit
+	if end > len(users) {
+		end = len(users)
+	}
+	return users[offset:end]
+}
+
+func writeUsers(t *testing.T, w http.ResponseWriter, users []model.User) {
+	t.Helper()
+	if err := json.NewEncoder(w).Encode(users); err != nil {
+		t.Errorf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"data-enricher-dispatcher/apperrors"
+)
+
+// Ping requests the first page of the source once, without retrying, and
+// reports why it did not answer with a 2xx status. The page is not read.
+func (c *apiClientV2) Ping(ctx context.Context) error {
+	pageURL, err := c.paginator.first(c.getUsersUrl)
+	if err != nil {
+		return apperrors.ApiClientPingError.AppendMessage(err)
+	}
+	resp, err := makePostRequestWithContext(withOperation(ctx, OperationPing), c.client, c.getUsersAuth, http.MethodGet, pageURL, "", nil, nil, defaultTimeout)
+	if err != nil {
+		return apperrors.ApiClientPingError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	resp.Body.Close()
+	if !isSuccess(resp.StatusCode) {
+		return apperrors.ApiClientPingError.AppendMessage(fmt.Errorf(unexpectedStatusCodeError, resp.StatusCode))
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+)
+
+func TestApiClientV2_Ping(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		pagination string
+		wantQuery  string
+		wantErr    bool
+	}{
+		{name: "reachable", statusCode: http.StatusOK},
+		{name: "first page", statusCode: http.StatusOK, pagination: "page", wantQuery: "limit=100&page=1"},
+		{name: "server error is not retried", statusCode: http.StatusServiceUnavailable, wantErr: true},
+		{name: "unauthorized", statusCode: http.StatusUnauthorized, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			var gotQuery string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				gotQuery = r.URL.RawQuery
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{GetUsersURL: server.URL, GetUsersPagination: tt.pagination, GetUsersPageParam: "page", GetUsersLimitParam: "limit", GetUsersPageSize: 100, RetryMaxAttempts: 3}
+			err := NewAPIClientV2(cfg).(Pinger).Ping(context.Background())
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !apperrors.Is(err, apperrors.ApiClientPingError) {
+				t.Errorf("expected %s, got %v", apperrors.ApiClientPingError.Code, err)
+			}
+			if calls != 1 {
+				t.Errorf("expected a single request, got %d", calls)
+			}
+			if gotQuery != tt.wantQuery {
+				t.Errorf("expected query %q, got %q", tt.wantQuery, gotQuery)
+			}
+		})
+	}
+}
+
+func TestApiClientV2_PingUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := NewAPIClientV2(&config.Config{GetUsersURL: server.URL}).(Pinger).Ping(context.Background())
+	if !apperrors.Is(err, apperrors.ApiClientPingError) {
+		t.Errorf("expected %s, got %v", apperrors.ApiClientPingError.Code, err)
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket holding up to burst tokens that refills at
+// rate tokens per second. A nil *RateLimiter never waits.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	waited time.Duration
+
+	now func() time.Time
+}
+
+// NewRateLimiter returns a limiter allowing rate requests per second with
+// bursts of up to burst requests, or nil when rate is not positive.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a token is available or ctx is done and returns how long
+// it waited.
+func (l *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	wait := l.reserve()
+	if wait <= 0 {
+		return 0, nil
+	}
+	if err := sleepContext(ctx, wait); err != nil {
+		l.cancel()
+		return 0, err
+	}
+
+	l.mu.Lock()
+	l.waited += wait
+	l.mu.Unlock()
+
+	return wait, nil
+}
+
+// Waited returns the total time callers spent in Wait.
+func (l *RateLimiter) Waited() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waited
+}
+
+// reserve takes a token, possibly going into debt, and returns how long the
+// caller has to wait until that token is actually available.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns the token of a reservation that was abandoned.
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+}
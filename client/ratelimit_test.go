@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(10, 2)
+	limiter.now = func() time.Time { return now }
+
+	expected := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, want := range expected {
+		if got := limiter.reserve(); got != want {
+			t.Errorf("reservation %d: expected wait %v, got %v", i+1, want, got)
+		}
+	}
+
+	now = now.Add(time.Second)
+	if got := limiter.reserve(); got != 0 {
+		t.Errorf("expected the bucket to refill after a second, got wait %v", got)
+	}
+}
+
+func TestRateLimiter_NilNeverWaits(t *testing.T) {
+	limiter := NewRateLimiter(0, 5)
+	if limiter != nil {
+		t.Fatalf("expected a nil limiter for a zero rate")
+	}
+	if wait, err := limiter.Wait(context.Background()); wait != 0 || err != nil {
+		t.Errorf("expected no wait, got %v, %v", wait, err)
+	}
+}
+
+func TestRateLimiter_WaitHonoursContext(t *testing.T) {
+	limiter := NewRateLimiter(0.1, 1)
+	if _, err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to be cut short, got %v", err)
+	}
+	if limiter.Waited() != 0 {
+		t.Errorf("expected abandoned waits not to be counted, got %v", limiter.Waited())
+	}
+}
+
+func TestApiClientV2_PostUserRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL, PostRateLimit: 50, PostRateBurst: 1})
+	// A frozen clock refills no tokens, so the three posts after the burst
+	// wait 20ms, 40ms and 60ms whatever the time the posts really took.
+	now := time.Now()
+	client.(*apiClientV2).postLimiter.now = func() time.Time { return now }
+	user := model.User{Name: "John Doe", Email: "john@email.com"}
+
+	for i := 0; i < 4; i++ {
+		if err := client.PostUser(context.Background(), user); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if waited := client.(StatsReporter).Stats().RateLimitWait; waited != 120*time.Millisecond {
+		t.Errorf("expected 4 posts at 50/s with a burst of 1 to wait 120ms on the limiter, waited %v", waited)
+	}
+}
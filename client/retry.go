@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/metrics"
+)
+
+const (
+	defaultInitialInterval = 500 * time.Millisecond
+	defaultMaxInterval     = 30 * time.Second
+	defaultMultiplier      = 2.0
+	defaultMaxElapsedTime  = 2 * time.Minute
+)
+
+var defaultRetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooEarly,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy decides whether and when a failed request is sent again. Waits
+// grow exponentially from InitialInterval by Multiplier up to MaxInterval and
+// are drawn with full jitter. A Retry-After header on 429 and 503 responses
+// replaces the computed wait.
+type RetryPolicy struct {
+	MaxAttempts          int
+	InitialInterval      time.Duration
+	MaxInterval          time.Duration
+	Multiplier           float64
+	MaxElapsedTime       time.Duration
+	RetryableStatusCodes map[int]bool
+
+	// jitter returns a random value in [0, 1). It is replaced in tests.
+	jitter func() float64
+	// sleep waits between attempts. It is replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+	// metrics counts the retries, when set.
+	metrics *metrics.Metrics
+}
+
+// NewRetryPolicy builds the policy described by cfg, falling back to the
+// defaults for unset values.
+func NewRetryPolicy(cfg *config.Config) *RetryPolicy {
+	multiplier := cfg.RetryMultiplier
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+	statusCodes := cfg.RetryStatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = defaultRetryableStatusCodes
+	}
+	retryable := make(map[int]bool, len(statusCodes))
+	for _, code := range statusCodes {
+		retryable[code] = true
+	}
+
+	return &RetryPolicy{
+		MaxAttempts:          intOrDefault(cfg.RetryMaxAttempts, defaultAttempts),
+		InitialInterval:      durationOrDefault(cfg.RetryInitialInterval, defaultInitialInterval),
+		MaxInterval:          durationOrDefault(cfg.RetryMaxInterval, defaultMaxInterval),
+		Multiplier:           multiplier,
+		MaxElapsedTime:       durationOrDefault(cfg.RetryMaxElapsedTime, defaultMaxElapsedTime),
+		RetryableStatusCodes: retryable,
+		jitter:               rand.Float64,
+		sleep:                sleepContext,
+	}
+}
+
+// do calls send until it yields a response that is not retried, the policy
+// gives up or ctx is done. A response with a non-2xx status is returned
+// as-is when it is the last one; the bodies of responses that are retried
+// are closed. The returned error is a *DeliveryError wrapping the last
+// transport or context error.
+func (p *RetryPolicy) do(ctx context.Context, send func() (*http.Response, error)) (*http.Response, int, error) {
+	startedAt := time.Now()
+	var lastStatusCode int
+
+	for attempt := 1; ; attempt++ {
+		resp, err := send()
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if !isRetryableError(err) {
+				return nil, attempt, &DeliveryError{Err: err, StatusCode: lastStatusCode, Attempts: attempt}
+			}
+			wait = p.backoff(attempt)
+		case isSuccess(resp.StatusCode):
+			return resp, attempt, nil
+		default:
+			lastStatusCode = resp.StatusCode
+			if !p.RetryableStatusCodes[resp.StatusCode] {
+				return resp, attempt, nil
+			}
+			wait = p.backoff(attempt)
+			if retryAfter, ok := parseRetryAfter(resp, time.Now()); ok {
+				wait = retryAfter
+			}
+		}
+
+		if attempt >= p.MaxAttempts || time.Since(startedAt)+wait > p.MaxElapsedTime {
+			if err != nil {
+				return nil, attempt, &DeliveryError{Err: err, StatusCode: lastStatusCode, Attempts: attempt}
+			}
+			return resp, attempt, nil
+		}
+		if resp != nil {
+			//nolint:errcheck
+			resp.Body.Close()
+		}
+
+		p.metrics.RequestRetried(operationOf(ctx))
+		if err := p.sleep(ctx, wait); err != nil {
+			return nil, attempt, &DeliveryError{Err: err, StatusCode: lastStatusCode, Attempts: attempt}
+		}
+	}
+}
+
+// backoff returns the full-jitter wait before retrying after attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt-1))
+	if ceiling > float64(p.MaxInterval) {
+		ceiling = float64(p.MaxInterval)
+	}
+	return time.Duration(p.jitter() * ceiling)
+}
+
+func isSuccess(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// isRetryableError reports whether a transport error is worth another
+// attempt: timeouts, refused or reset connections and truncated responses.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter reads the Retry-After header of 429 and 503 responses,
+// given either as delay seconds or as an HTTP date.
+func parseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func durationOrDefault(value, fallback time.Duration) time.Duration {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
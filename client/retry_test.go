@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := NewRetryPolicy(&config.Config{
+		RetryInitialInterval: 100 * time.Millisecond,
+		RetryMaxInterval:     time.Second,
+		RetryMultiplier:      2,
+	})
+	policy.jitter = func() float64 { return 0.5 }
+
+	expected := []time.Duration{
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		500 * time.Millisecond,
+		500 * time.Millisecond,
+	}
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name       string
+		statusCode int
+		header     string
+		expected   time.Duration
+		ok         bool
+	}{
+		{name: "seconds on 429", statusCode: http.StatusTooManyRequests, header: "3", expected: 3 * time.Second, ok: true},
+		{name: "http date on 503", statusCode: http.StatusServiceUnavailable, header: now.Add(5 * time.Second).Format(http.TimeFormat), expected: 5 * time.Second, ok: true},
+		{name: "ignored on 500", statusCode: http.StatusInternalServerError, header: "3"},
+		{name: "missing header", statusCode: http.StatusTooManyRequests},
+		{name: "garbage", statusCode: http.StatusTooManyRequests, header: "soon"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tc.statusCode, Header: http.Header{}}
+			if tc.header != "" {
+				resp.Header.Set("Retry-After", tc.header)
+			}
+			got, ok := parseRetryAfter(resp, now)
+			if got != tc.expected || ok != tc.ok {
+				t.Errorf("parseRetryAfter() = %v, %v, want %v, %v", got, ok, tc.expected, tc.ok)
+			}
+		})
+	}
+}
+
+func TestApiClientV2_PostUserRetries(t *testing.T) {
+	testCases := []struct {
+		name             string
+		statuses         []int
+		expectedCalls    int32
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{name: "recovers after 503", statuses: []int{503, 200}, expectedCalls: 2},
+		{name: "accepts 201", statuses: []int{201}, expectedCalls: 1},
+		{name: "does not retry 400", statuses: []int{400, 200}, expectedCalls: 1, expectedStatus: 400, expectedAttempts: 1},
+		{name: "gives up after max attempts", statuses: []int{500, 500, 500, 200}, expectedCalls: 3, expectedStatus: 500, expectedAttempts: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tc.statuses[call-1])
+			}))
+			defer server.Close()
+
+			client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL, RetryInitialInterval: time.Millisecond})
+			err := client.PostUser(context.Background(), model.User{Name: "John Doe", Email: "john@email.com"})
+
+			if got := atomic.LoadInt32(&calls); got != tc.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tc.expectedCalls, got)
+			}
+			if tc.expectedStatus == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var deliveryErr *DeliveryError
+			if !errors.As(err, &deliveryErr) {
+				t.Fatalf("expected a delivery error, got %v", err)
+			}
+			if deliveryErr.StatusCode != tc.expectedStatus || deliveryErr.Attempts != tc.expectedAttempts {
+				t.Errorf("expected status %d after %d attempts, got %d after %d",
+					tc.expectedStatus, tc.expectedAttempts, deliveryErr.StatusCode, deliveryErr.Attempts)
+			}
+			if !apperrors.Is(err, apperrors.ApiClientPostUserPostError) {
+				t.Errorf("expected %s, got %v", apperrors.ApiClientPostUserPostError.Code, err)
+			}
+		})
+	}
+}
+
+func TestApiClientV2_PostUserHonoursRetryAfterAndContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL, RetryInitialInterval: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	startedAt := time.Now()
+	err := client.PostUser(ctx, model.User{Name: "John Doe", Email: "john@email.com"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to be cut short by the context, got %v", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Errorf("expected to stop waiting when the context expired, waited %v", elapsed)
+	}
+}
+
+func TestApiClientV2_PostUserWaitsOutLongRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "11")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{PostUsersURL: server.URL})
+	var waits []time.Duration
+	client.(*apiClientV2).retryPolicy.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	if err := client.PostUser(context.Background(), model.User{Name: "John Doe", Email: "john@email.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(waits) != 1 || waits[0] != 11*time.Second {
+		t.Errorf("expected a single 11s wait, got %v", waits)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected 2 calls, got %d", got)
+	}
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const unexpectedTokenError = "expected start of JSON array, got %v"
+
+// pageIterator decodes the users of each page element by element, so only
+// the user currently being handed out is held in memory. The next page is
+// requested once the current one is exhausted.
+type pageIterator struct {
+	ctx    context.Context
+	client *apiClientV2
+
+	pageURL string
+	page    int
+	resp    *http.Response
+	decoder *json.Decoder
+	count   int
+
+	user     model.User
+	rejected error
+	err      error
+}
+
+func (c *apiClientV2) StreamUsers(ctx context.Context) (UserIterator, error) {
+	pageURL, err := c.paginator.first(c.getUsersUrl)
+	if err != nil {
+		return nil, apperrors.ApiClientGetUsersPaginationError.AppendMessage(fmt.Errorf(pageError, err, 1))
+	}
+
+	it := &pageIterator{ctx: withOperation(ctx, OperationGetUsers), client: c, pageURL: pageURL}
+	if err := it.openPage(); err != nil {
+		//nolint:errcheck
+		it.Close()
+		return nil, err
+	}
+
+	return it, nil
+}
+
+func (c *apiClientV2) StreamUsersFrom(ctx context.Context, position Position) (UserIterator, error) {
+	it := &pageIterator{ctx: withOperation(ctx, OperationGetUsers), client: c, pageURL: position.PageURL, page: position.Page - 1}
+	if err := it.openPage(); err != nil {
+		//nolint:errcheck
+		it.Close()
+		return nil, err
+	}
+
+	return it, nil
+}
+
+func (it *pageIterator) Next() bool {
+	for it.err == nil {
+		if it.decoder == nil {
+			if it.pageURL == "" {
+				return false
+			}
+			if it.err = it.openPage(); it.err != nil {
+				return false
+			}
+		}
+
+		if it.decoder.More() {
+			var record json.RawMessage
+			if err := it.decoder.Decode(&record); err != nil {
+				it.err = apperrors.ApiClientGetUsersUnmarshalError.AppendMessage(fmt.Errorf(pageError, err, it.page))
+				return false
+			}
+			var user model.User
+			it.rejected = nil
+			if it.client.records != nil {
+				it.rejected = it.client.records.Validate(record)
+			}
+			// A rejected record may not fit the model at all; whatever did
+			// decode is kept so the rejection can name the user.
+			if err := json.Unmarshal(record, &user); err != nil && it.rejected == nil {
+				it.err = apperrors.ApiClientGetUsersUnmarshalError.AppendMessage(fmt.Errorf(pageError, err, it.page))
+				return false
+			}
+			it.user = user
+			it.count++
+			return true
+		}
+
+		it.err = it.closePage()
+	}
+
+	return false
+}
+
+func (it *pageIterator) User() model.User {
+	return it.user
+}
+
+func (it *pageIterator) Position() Position {
+	return Position{PageURL: it.pageURL, Page: it.page, Index: it.count - 1}
+}
+
+func (it *pageIterator) Rejected() error {
+	return it.rejected
+}
+
+func (it *pageIterator) Err() error {
+	return it.err
+}
+
+func (it *pageIterator) Close() error {
+	if it.resp == nil {
+		return nil
+	}
+	err := it.resp.Body.Close()
+	it.resp, it.decoder = nil, nil
+	if err != nil {
+		return apperrors.ApiClientGetUsersCloseBodyError.AppendMessage(err)
+	}
+	return nil
+}
+
+// openPage requests it.pageURL and consumes the opening bracket of its array.
+func (it *pageIterator) openPage() error {
+	it.page++
+	if it.page > it.client.maxPages {
+		return apperrors.ApiClientGetUsersMaxPagesExceededError.AppendMessage(fmt.Errorf(maxPagesExceededError, it.client.maxPages))
+	}
+
+	req, err := http.NewRequestWithContext(it.ctx, http.MethodGet, it.pageURL, nil)
+	if err != nil {
+		return apperrors.ApiClientGetUsersRequestError.AppendMessage(fmt.Errorf(pageError, err, it.page))
+	}
+
+	resp, attempts, err := it.client.retryPolicy.do(it.ctx, func() (*http.Response, error) {
+		return doWithAuth(it.client.client, it.client.getUsersAuth, req.Clone(it.ctx))
+	})
+	if err != nil {
+		return apperrors.ApiClientGetUsersGetError.AppendMessage(fmt.Errorf(pageError, err, it.page))
+	}
+	if resp.StatusCode != http.StatusOK {
+		//nolint:errcheck
+		resp.Body.Close()
+		err = fmt.Errorf(unexpectedStatusCodePageError, resp.StatusCode, it.page, attempts)
+		return &DeliveryError{
+			Err:        apperrors.ApiClientGetUsersStatusCodeNotOkError.AppendMessage(err),
+			StatusCode: resp.StatusCode,
+			Attempts:   attempts,
+		}
+	}
+
+	it.resp = resp
+	it.decoder = json.NewDecoder(resp.Body)
+	it.count = 0
+
+	token, err := it.decoder.Token()
+	if err != nil {
+		return apperrors.ApiClientGetUsersUnmarshalError.AppendMessage(fmt.Errorf(pageError, err, it.page))
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		err = fmt.Errorf(unexpectedTokenError, token)
+		return apperrors.ApiClientGetUsersUnmarshalError.AppendMessage(fmt.Errorf(pageError, err, it.page))
+	}
+
+	return nil
+}
+
+// closePage consumes the closing bracket of the current page and works out
+// the URL of the next one.
+func (it *pageIterator) closePage() error {
+	if _, err := it.decoder.Token(); err != nil {
+		return apperrors.ApiClientGetUsersUnmarshalError.AppendMessage(fmt.Errorf(pageError, err, it.page))
+	}
+	header := it.resp.Header
+	if err := it.Close(); err != nil {
+		return err
+	}
+	if it.count == 0 && it.page == 1 {
+		return apperrors.ApiClientGetUsersEmptyResponseError.AppendMessage(fmt.Errorf(emptyResponseError, 0))
+	}
+
+	next, err := it.client.paginator.next(it.pageURL, header, it.count)
+	if err != nil {
+		return apperrors.ApiClientGetUsersPaginationError.AppendMessage(fmt.Errorf(pageError, err, it.page))
+	}
+	it.pageURL = next
+
+	return nil
+}
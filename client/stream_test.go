@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+func TestApiClientV2_StreamUsers(t *testing.T) {
+	users := make([]model.User, 0, 7)
+	for i := 0; i < 7; i++ {
+		users = append(users, model.User{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@email.com", i)})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		writeUsers(t, w, pageOf(users, (page-1)*3, 3))
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{GetUsersURL: server.URL, GetUsersPagination: PaginationPage, GetUsersPageSize: 3})
+	it, err := client.StreamUsers(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer it.Close()
+
+	var got []model.User
+	for it.Next() {
+		got = append(got, it.User())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("unexpected iteration error: %v", err)
+	}
+	if len(got) != len(users) {
+		t.Fatalf("expected %d users, got %d", len(users), len(got))
+	}
+	for i := range got {
+		if !got[i].IsEqual(&users[i]) {
+			t.Errorf("expected user %v, got %v", users[i], got[i])
+		}
+	}
+}
+
+func TestApiClientV2_StreamUsersErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		expectUsers int
+		expectedErr *apperrors.AppError
+	}{
+		{
+			name:        "malformed element after valid ones",
+			body:        `[{"name":"A","email":"a@email.com"},{"name":}]`,
+			expectUsers: 1,
+			expectedErr: apperrors.ApiClientGetUsersUnmarshalError,
+		},
+		{
+			name:        "not an array",
+			body:        `{"name":"A","email":"a@email.com"}`,
+			expectedErr: apperrors.ApiClientGetUsersUnmarshalError,
+		},
+		{
+			name:        "empty array",
+			body:        `[]`,
+			expectedErr: apperrors.ApiClientGetUsersEmptyResponseError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.body)
+			}))
+			defer server.Close()
+
+			client := NewAPIClientV2(&config.Config{GetUsersURL: server.URL})
+			it, err := client.StreamUsers(context.Background())
+			if err == nil {
+				defer it.Close()
+				count := 0
+				for it.Next() {
+					count++
+				}
+				if count != tc.expectUsers {
+					t.Errorf("expected %d users before the error, got %d", tc.expectUsers, count)
+				}
+				err = it.Err()
+			}
+			if !apperrors.Is(err, tc.expectedErr) {
+				t.Errorf("expected %s, got %v", tc.expectedErr.Code, err)
+			}
+		})
+	}
+}
+
+func TestApiClientV2_StreamUsersDeliveryError(t *testing.T) {
+	testCases := []struct {
+		name           string
+		closed         bool
+		expectedErr    *apperrors.AppError
+		expectedStatus int
+	}{
+		{name: "status code", expectedErr: apperrors.ApiClientGetUsersStatusCodeNotOkError, expectedStatus: http.StatusServiceUnavailable},
+		{name: "unreachable", closed: true, expectedErr: apperrors.ApiClientGetUsersGetError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			if tc.closed {
+				server.Close()
+			}
+			defer server.Close()
+
+			client := NewAPIClientV2(&config.Config{GetUsersURL: server.URL, RetryMaxAttempts: 2, RetryInitialInterval: time.Millisecond})
+			_, err := client.StreamUsers(context.Background())
+			if !apperrors.Is(err, tc.expectedErr) {
+				t.Errorf("expected %s, got %v", tc.expectedErr.Code, err)
+			}
+			var deliveryErr *DeliveryError
+			if !errors.As(err, &deliveryErr) {
+				t.Fatalf("expected a delivery error, got %v", err)
+			}
+			if deliveryErr.StatusCode != tc.expectedStatus || deliveryErr.Attempts != 2 {
+				t.Errorf("expected status %d after 2 attempts, got %d after %d", tc.expectedStatus, deliveryErr.StatusCode, deliveryErr.Attempts)
+			}
+		})
+	}
+}
+
+// idRequired rejects records without a numeric "id".
+type idRequired struct{}
+
+func (idRequired) Validate(record []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(record, &fields); err != nil {
+		return err
+	}
+	if _, err := strconv.Atoi(string(fields["id"])); err != nil {
+		return fmt.Errorf("id is not a number: %s", fields["id"])
+	}
+	return nil
+}
+
+func TestApiClientV2_StreamUsersRecordValidator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":1,"name":"A","email":"a@email.com"},{"id":"two","name":"B","email":"b@email.com"},{"name":"C","email":"c@email.com"}]`)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientV2(&config.Config{GetUsersURL: server.URL}, WithRecordValidator(idRequired{}))
+	it, err := client.StreamUsers(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer it.Close()
+
+	var accepted, rejected []string
+	for it.Next() {
+		if it.Rejected() != nil {
+			rejected = append(rejected, it.User().Email)
+			continue
+		}
+		accepted = append(accepted, it.User().Email)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("unexpected iteration error: %v", err)
+	}
+	if len(accepted) != 1 || accepted[0] != "a@email.com" {
+		t.Errorf("expected only a@email.com to be accepted, got %v", accepted)
+	}
+	// The record whose id does not decode is still handed out, as far as it
+	// decodes, so it can be reported.
+	if len(rejected) != 2 || rejected[0] != "b@email.com" || rejected[1] != "c@email.com" {
+		t.Errorf("expected b@email.com and c@email.com to be rejected, got %v", rejected)
+	}
+}
+
+func TestApiClientV2_StreamUsersFrom(t *testing.T) {
+	users := make([]model.User, 0, 7)
+	for i := 0; i < 7; i++ {
+		users = append(users, model.User{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@email.com", i)})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		writeUsers(t, w, pageOf(users, (page-1)*3, 3))
+	}))
+	defer server.Close()
+
+	c := NewAPIClientV2(&config.Config{GetUsersURL: server.URL, GetUsersPagination: PaginationPage, GetUsersPageSize: 3})
+	it, err := c.StreamUsers(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var fifth Position
+	for i := 0; it.Next(); i++ {
+		if i == 4 {
+			fifth = it.(Positioner).Position()
+		}
+	}
+	//nolint:errcheck
+	it.Close()
+	if fifth.Page != 2 || fifth.Index != 1 {
+		t.Fatalf("expected the fifth user at page 2, index 1, got %+v", fifth)
+	}
+
+	it, err = c.(Resumer).StreamUsersFrom(context.Background(), fifth)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer it.Close()
+	var got []model.User
+	for it.Next() {
+		got = append(got, it.User())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("unexpected iteration error: %v", err)
+	}
+	if len(got) != 4 || !got[0].IsEqual(&users[3]) || !got[3].IsEqual(&users[6]) {
+		t.Errorf("expected users 3 to 6 from the start of page 2, got %v", got)
+	}
+}
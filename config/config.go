@@ -1,17 +1,199 @@
 package config
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/filter"
+	"data-enricher-dispatcher/scheduler"
 
 	"github.com/caarlos0/env/v8"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Environment      string   `env:"ENVIRONMENT,required"`
-	GetUsersURL      string   `env:"GET_USERS_URL,required"`
-	PostUsersURL     string   `env:"POST_USERS_URL,required"`
+	Environment  string `env:"ENVIRONMENT,required"`
+	GetUsersURL  string `env:"GET_USERS_URL,required"`
+	PostUsersURL string `env:"POST_USERS_URL,required"`
+	// IncludeEmailSuffixes, when set, limits dispatching to users whose email
+	// domain matches one of them; users matching ExcludeEmailSuffixes are
+	// never dispatched. See model.EmailMatchesSuffix for the matching rules.
+	IncludeEmailSuffixes []string `env:"INCLUDE_EMAIL_SUFFIXES" envSeparator:","`
+	ExcludeEmailSuffixes []string `env:"EXCLUDE_EMAIL_SUFFIXES" envSeparator:","`
+	// ExcludePostfixes is merged into ExcludeEmailSuffixes by NewConfig.
+	//
+	// Deprecated: use EXCLUDE_EMAIL_SUFFIXES.
 	ExcludePostfixes []string `env:"EXCLUDE_POSTFIXES" envSeparator:","`
+	// FilterInclude and FilterExclude hold ";" separated filter rules, see
+	// filter.Filter for their syntax.
+	FilterInclude string `env:"FILTER_INCLUDE"`
+	FilterExclude string `env:"FILTER_EXCLUDE"`
+	// EmailNormalize trims and lowercases emails, converting internationalized
+	// domains to punycode, before users are filtered and validated.
+	EmailNormalize bool `env:"EMAIL_NORMALIZE" envDefault:"true"`
+	// EmailStrictDomain rejects email domains that could not be host names,
+	// such as "localhost" or "example.123". It never looks up DNS records.
+	EmailStrictDomain   bool `env:"EMAIL_STRICT_DOMAIN" envDefault:"false"`
+	DispatchConcurrency int  `env:"DISPATCH_CONCURRENCY" envDefault:"1"`
+	// ShutdownGraceTimeout is how long posts in flight when a shutdown is
+	// requested may take to complete before they are cancelled.
+	ShutdownGraceTimeout time.Duration `env:"SHUTDOWN_GRACE_TIMEOUT" envDefault:"30s"`
+	ReportPath           string        `env:"REPORT_PATH"`
+	DeadLetterPath       string        `env:"DEAD_LETTER_PATH"`
+	LedgerPath           string        `env:"LEDGER_PATH"`
+	// CheckpointPath enables checkpoints: how far a run got through the
+	// source is saved there every CheckpointInterval, for -resume.
+	CheckpointPath     string        `env:"CHECKPOINT_PATH"`
+	CheckpointInterval time.Duration `env:"CHECKPOINT_INTERVAL" envDefault:"10s"`
+	// SnapshotPath enables change-data-capture mode: only users that changed
+	// since the snapshot stored there are posted.
+	SnapshotPath string `env:"SNAPSHOT_PATH"`
+	// DeleteUsersURL receives users that disappeared from the source. A
+	// "{key}" placeholder is replaced with the user's key.
+	DeleteUsersURL string `env:"DELETE_USERS_URL"`
+	// TransformPath points to a JSON or YAML file of rules reshaping users
+	// into the payloads the sink expects.
+	TransformPath string `env:"TRANSFORM_PATH"`
+	// SchemaPath points to a JSON Schema that every source record and every
+	// outgoing payload must match. PayloadSchemaPath replaces it for the
+	// payloads, for when a transformation gives them another shape; with a
+	// transformation and no PayloadSchemaPath, payloads are not checked.
+	SchemaPath        string `env:"SCHEMA_PATH"`
+	PayloadSchemaPath string `env:"PAYLOAD_SCHEMA_PATH"`
+
+	// ScheduleCron or ScheduleInterval sets when the daemon mode starts
+	// dispatch cycles; ScheduleJitter delays each start by up to that much.
+	ScheduleCron       string        `env:"SCHEDULE_CRON"`
+	ScheduleInterval   time.Duration `env:"SCHEDULE_INTERVAL"`
+	ScheduleJitter     time.Duration `env:"SCHEDULE_JITTER" envDefault:"0s"`
+	ScheduleStatusPath string        `env:"SCHEDULE_STATUS_PATH"`
+
+	// AdminAddr is the address the /healthz, /readyz and /status endpoints
+	// are served on. They are disabled when it is empty.
+	AdminAddr string `env:"ADMIN_ADDR"`
+	// AdminProbeTimeout bounds how long the source may take to answer the
+	// ping of a readiness probe. AdminPingTTL is how long the outcome of a
+	// ping is reused by the following probes; zero pings on every probe.
+	AdminProbeTimeout time.Duration `env:"ADMIN_PROBE_TIMEOUT" envDefault:"5s"`
+	AdminPingTTL      time.Duration `env:"ADMIN_PING_TTL" envDefault:"15s"`
+	// MetricsAddr is the address Prometheus metrics are served on, under
+	// /metrics. They are disabled when it is empty.
+	MetricsAddr string `env:"METRICS_ADDR"`
+	// TracingExporter sends OpenTelemetry spans to stdout or to the OTLP/HTTP
+	// collector at TracingOTLPEndpoint; "none" disables tracing. When the
+	// endpoint is empty the standard OTEL_EXPORTER_OTLP_* variables apply.
+	TracingExporter     string  `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingOTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	GetUsersPagination   string `env:"GET_USERS_PAGINATION" envDefault:"none"`
+	GetUsersPageParam    string `env:"GET_USERS_PAGE_PARAM" envDefault:"page"`
+	GetUsersLimitParam   string `env:"GET_USERS_LIMIT_PARAM" envDefault:"limit"`
+	GetUsersPageSize     int    `env:"GET_USERS_PAGE_SIZE" envDefault:"100"`
+	GetUsersCursorParam  string `env:"GET_USERS_CURSOR_PARAM" envDefault:"cursor"`
+	GetUsersCursorHeader string `env:"GET_USERS_CURSOR_HEADER" envDefault:"X-Next-Cursor"`
+	GetUsersMaxPages     int    `env:"GET_USERS_MAX_PAGES" envDefault:"1000"`
+
+	RetryMaxAttempts     int           `env:"RETRY_MAX_ATTEMPTS" envDefault:"3"`
+	RetryInitialInterval time.Duration `env:"RETRY_INITIAL_INTERVAL" envDefault:"500ms"`
+	RetryMaxInterval     time.Duration `env:"RETRY_MAX_INTERVAL" envDefault:"30s"`
+	RetryMultiplier      float64       `env:"RETRY_MULTIPLIER" envDefault:"2"`
+	RetryMaxElapsedTime  time.Duration `env:"RETRY_MAX_ELAPSED_TIME" envDefault:"2m"`
+	RetryStatusCodes     []int         `env:"RETRY_STATUS_CODES" envSeparator:"," envDefault:"408,425,429,500,502,503,504"`
+
+	PostUsersBatchURL string `env:"POST_USERS_BATCH_URL"`
+	PostBatchSize     int    `env:"POST_BATCH_SIZE" envDefault:"1"`
+	PostBatchMaxBytes int    `env:"POST_BATCH_MAX_BYTES" envDefault:"1048576"`
+
+	PostRateLimit float64 `env:"POST_RATE_LIMIT" envDefault:"0"`
+	PostRateBurst int     `env:"POST_RATE_BURST" envDefault:"1"`
+
+	GetUsersAuth  AuthConfig `envPrefix:"GET_USERS_AUTH_"`
+	PostUsersAuth AuthConfig `envPrefix:"POST_USERS_AUTH_"`
+
+	// Enrichers lists the enrichers run on every user, in order, each as
+	// "name" or "name:policy".
+	Enrichers          []string `env:"ENRICHERS" envSeparator:","`
+	EnrichCSVPath      string   `env:"ENRICH_CSV_PATH"`
+	EnrichCSVKeyColumn string   `env:"ENRICH_CSV_KEY_COLUMN" envDefault:"email"`
+	EnrichCSVUserField string   `env:"ENRICH_CSV_USER_FIELD" envDefault:"email"`
+	EnrichCSVPrefix    string   `env:"ENRICH_CSV_PREFIX"`
+	// EnrichHTTPURL is the URL template of the http_lookup enricher, such as
+	// "https://api.example.com/companies/{email_domain}".
+	EnrichHTTPURL string `env:"ENRICH_HTTP_URL"`
+	// EnrichHTTPFields selects response fields as "path" or "path:target";
+	// nested paths are dotted and targets default to the last path segment.
+	EnrichHTTPFields    []string      `env:"ENRICH_HTTP_FIELDS" envSeparator:","`
+	EnrichHTTPPrefix    string        `env:"ENRICH_HTTP_PREFIX"`
+	EnrichHTTPTimeout   time.Duration `env:"ENRICH_HTTP_TIMEOUT" envDefault:"10s"`
+	EnrichHTTPCacheSize int           `env:"ENRICH_HTTP_CACHE_SIZE" envDefault:"1000"`
+	EnrichHTTPCacheTTL  time.Duration `env:"ENRICH_HTTP_CACHE_TTL" envDefault:"10m"`
+	EnrichHTTPAuth      AuthConfig    `envPrefix:"ENRICH_HTTP_AUTH_"`
+}
+
+// AuthConfig describes how requests to one endpoint are authenticated.
+// Type is one of none, bearer, api_key, basic or oauth2.
+type AuthConfig struct {
+	Type         string   `env:"TYPE" envDefault:"none"`
+	Token        string   `env:"TOKEN"`
+	APIKeyHeader string   `env:"API_KEY_HEADER" envDefault:"X-API-Key"`
+	APIKey       string   `env:"API_KEY"`
+	Username     string   `env:"USERNAME"`
+	Password     string   `env:"PASSWORD"`
+	TokenURL     string   `env:"TOKEN_URL"`
+	ClientID     string   `env:"CLIENT_ID"`
+	ClientSecret string   `env:"CLIENT_SECRET"`
+	Scopes       []string `env:"SCOPES" envSeparator:","`
+}
+
+// String keeps secrets out of logs when the configuration is printed.
+func (a AuthConfig) String() string {
+	return fmt.Sprintf("{Type:%s Username:%s TokenURL:%s ClientID:%s Scopes:%v}", a.Type, a.Username, a.TokenURL, a.ClientID, a.Scopes)
+}
+
+func (a AuthConfig) validate(prefix string) error {
+	var missing []string
+	require := func(name, value string) {
+		if value == "" {
+			missing = append(missing, prefix+name)
+		}
+	}
+
+	switch strings.ToLower(a.Type) {
+	case "", "none":
+	case "bearer":
+		require("TOKEN", a.Token)
+	case "api_key":
+		require("API_KEY", a.APIKey)
+	case "basic":
+		require("USERNAME", a.Username)
+	case "oauth2":
+		require("TOKEN_URL", a.TokenURL)
+		require("CLIENT_ID", a.ClientID)
+		require("CLIENT_SECRET", a.ClientSecret)
+	default:
+		return fmt.Errorf("unknown %sTYPE %q", prefix, a.Type)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s auth requires %s", a.Type, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+var paginationStrategies = map[string]bool{
+	"none":   true,
+	"page":   true,
+	"cursor": true,
+	"link":   true,
+}
+
+var tracingExporters = map[string]bool{
+	"none":   true,
+	"stdout": true,
+	"otlp":   true,
 }
 
 func NewConfig(envFile string) (*Config, error) {
@@ -26,5 +208,89 @@ func NewConfig(envFile string) (*Config, error) {
 		return cfg, apperrors.EnvConfigParseError.AppendMessage(err)
 	}
 
+	cfg.ExcludeEmailSuffixes = append(cfg.ExcludeEmailSuffixes, cfg.ExcludePostfixes...)
+
+	err = cfg.validate()
+	if err != nil {
+		return cfg, apperrors.EnvConfigValidateError.AppendMessage(err)
+	}
+
 	return cfg, nil
 }
+
+func (cfg *Config) validate() error {
+	if !paginationStrategies[strings.ToLower(cfg.GetUsersPagination)] {
+		return fmt.Errorf("unknown GET_USERS_PAGINATION %q", cfg.GetUsersPagination)
+	}
+	if cfg.GetUsersMaxPages <= 0 {
+		return fmt.Errorf("GET_USERS_MAX_PAGES must be positive, got %d", cfg.GetUsersMaxPages)
+	}
+	if cfg.RetryMaxAttempts <= 0 {
+		return fmt.Errorf("RETRY_MAX_ATTEMPTS must be positive, got %d", cfg.RetryMaxAttempts)
+	}
+	if cfg.RetryMultiplier < 1 {
+		return fmt.Errorf("RETRY_MULTIPLIER must be at least 1, got %v", cfg.RetryMultiplier)
+	}
+	if cfg.PostBatchSize <= 0 {
+		return fmt.Errorf("POST_BATCH_SIZE must be positive, got %d", cfg.PostBatchSize)
+	}
+	if cfg.PostRateLimit < 0 {
+		return fmt.Errorf("POST_RATE_LIMIT must not be negative, got %v", cfg.PostRateLimit)
+	}
+	if err := validateEmailSuffixes("INCLUDE_EMAIL_SUFFIXES", cfg.IncludeEmailSuffixes); err != nil {
+		return err
+	}
+	if err := validateEmailSuffixes("EXCLUDE_EMAIL_SUFFIXES", cfg.ExcludeEmailSuffixes); err != nil {
+		return err
+	}
+	if _, err := filter.New(cfg.FilterInclude, cfg.FilterExclude); err != nil {
+		return err
+	}
+	if cfg.ScheduleCron != "" || cfg.ScheduleInterval != 0 {
+		if _, err := scheduler.FromConfig(cfg.ScheduleCron, cfg.ScheduleInterval); err != nil {
+			return err
+		}
+	}
+	if cfg.ShutdownGraceTimeout < 0 {
+		return fmt.Errorf("SHUTDOWN_GRACE_TIMEOUT must not be negative, got %s", cfg.ShutdownGraceTimeout)
+	}
+	if cfg.ScheduleJitter < 0 {
+		return fmt.Errorf("SCHEDULE_JITTER must not be negative, got %s", cfg.ScheduleJitter)
+	}
+	if cfg.AdminProbeTimeout <= 0 {
+		return fmt.Errorf("ADMIN_PROBE_TIMEOUT must be positive, got %s", cfg.AdminProbeTimeout)
+	}
+	if cfg.AdminPingTTL < 0 {
+		return fmt.Errorf("ADMIN_PING_TTL must not be negative, got %s", cfg.AdminPingTTL)
+	}
+	if !tracingExporters[strings.ToLower(cfg.TracingExporter)] {
+		return fmt.Errorf("unknown TRACING_EXPORTER %q", cfg.TracingExporter)
+	}
+	if cfg.TracingSampleRatio < 0 || cfg.TracingSampleRatio > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %v", cfg.TracingSampleRatio)
+	}
+	if cfg.EnrichHTTPCacheSize < 0 {
+		return fmt.Errorf("ENRICH_HTTP_CACHE_SIZE must not be negative, got %d", cfg.EnrichHTTPCacheSize)
+	}
+	if err := cfg.GetUsersAuth.validate("GET_USERS_AUTH_"); err != nil {
+		return err
+	}
+	if err := cfg.PostUsersAuth.validate("POST_USERS_AUTH_"); err != nil {
+		return err
+	}
+	if err := cfg.EnrichHTTPAuth.validate("ENRICH_HTTP_AUTH_"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateEmailSuffixes(name string, suffixes []string) error {
+	for _, suffix := range suffixes {
+		suffix = strings.TrimSpace(suffix)
+		if strings.Trim(suffix, "@.") == "" || strings.LastIndex(suffix, "@") > 0 {
+			return fmt.Errorf("%s has an invalid suffix %q", name, suffix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package deadletter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	maxLineSize    = 10 * 1024 * 1024
+	malformedEntry = "line %d: %v"
+)
+
+// Entry is a user that could not be delivered, together with what is known
+// about the last attempt.
+type Entry struct {
+	User       model.User `json:"user"`
+	StatusCode int        `json:"status_code,omitempty"`
+	ErrorCode  string     `json:"error_code"`
+	Error      string     `json:"error"`
+	Attempts   int        `json:"attempts"`
+	FailedAt   time.Time  `json:"failed_at"`
+}
+
+// Sink stores dead-lettered users. Implementations must be safe for
+// concurrent use by the dispatcher workers.
+type Sink interface {
+	Write(ctx context.Context, entry Entry) error
+	Close() error
+}
+
+// Rotator is implemented by sinks backed by a file that may be moved away
+// while the sink is in use. After Rotate the next write starts a new file.
+type Rotator interface {
+	Rotate() error
+}
+
+type fileSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileSink returns a Sink appending one JSON entry per line to path. The
+// file is opened on the first write, so nothing is created for clean runs.
+func NewFileSink(path string) Sink {
+	return &fileSink{path: path}
+}
+
+func (s *fileSink) Write(_ context.Context, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return apperrors.DeadLetterWriteError.AppendMessage(err)
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return apperrors.DeadLetterOpenError.AppendMessage(err)
+		}
+		s.file = file
+	}
+	if _, err := s.file.Write(data); err != nil {
+		return apperrors.DeadLetterWriteError.AppendMessage(err)
+	}
+
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return apperrors.DeadLetterCloseError.AppendMessage(err)
+	}
+	return nil
+}
+
+func (s *fileSink) Rotate() error {
+	return s.Close()
+}
+
+// ReadFile loads every entry of a JSONL dead-letter file. A missing file
+// holds no entries.
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apperrors.DeadLetterOpenError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, apperrors.DeadLetterReadError.AppendMessage(fmt.Errorf(malformedEntry, line, err))
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, apperrors.DeadLetterReadError.AppendMessage(err)
+	}
+
+	return entries, nil
+}
+
+// WriteFile replaces the dead-letter file at path with entries, atomically
+// so an interruption never loses any of them. The file is removed when there
+// are no entries left.
+func WriteFile(path string, entries []Entry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return apperrors.DeadLetterWriteError.AppendMessage(err)
+		}
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return apperrors.DeadLetterOpenError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			//nolint:errcheck
+			tmp.Close()
+			return apperrors.DeadLetterWriteError.AppendMessage(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		//nolint:errcheck
+		tmp.Close()
+		return apperrors.DeadLetterWriteError.AppendMessage(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return apperrors.DeadLetterCloseError.AppendMessage(err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return apperrors.DeadLetterWriteError.AppendMessage(err)
+	}
+
+	return nil
+}
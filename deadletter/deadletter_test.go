@@ -0,0 +1,86 @@
+package deadletter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+func TestFileSink_WriteAndReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	sink := NewFileSink(path)
+
+	entries := []Entry{
+		{
+			User:       model.User{Name: "John Doe", Email: "john@email.com"},
+			StatusCode: 503,
+			ErrorCode:  "API_CLIENT_POST_USER_POST_ERROR",
+			Error:      "unexpected status code: 503, attempts: 3",
+			Attempts:   3,
+			FailedAt:   time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			User:      model.User{Name: "Jane Doe", Email: "jane@email.com"},
+			ErrorCode: "API_CLIENT_MAKE_POST_REQUEST_WITH_RETRY_ERROR",
+			Error:     "connection refused",
+			Attempts:  1,
+			FailedAt:  time.Date(2025, 1, 2, 3, 4, 6, 0, time.UTC),
+		},
+	}
+	for _, entry := range entries {
+		if err := sink.Write(context.Background(), entry); err != nil {
+			t.Fatalf("unexpected write error: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("expected %d entries, got %d", len(entries), len(got))
+	}
+	for i := range got {
+		if !got[i].User.IsEqual(&entries[i].User) || got[i].StatusCode != entries[i].StatusCode ||
+			got[i].ErrorCode != entries[i].ErrorCode || got[i].Attempts != entries[i].Attempts ||
+			!got[i].FailedAt.Equal(entries[i].FailedAt) {
+			t.Errorf("expected entry %+v, got %+v", entries[i], got[i])
+		}
+	}
+}
+
+func TestFileSink_NoWritesCreatesNoFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	sink := NewFileSink(path)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no file to be created, got %v", err)
+	}
+
+	entries, err := ReadFile(path)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries and no error, got %v, %v", entries, err)
+	}
+}
+
+func TestReadFile_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	if err := os.WriteFile(path, []byte("{\"user\":{}}\nnot json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ReadFile(path)
+	if !apperrors.Is(err, apperrors.DeadLetterReadError) {
+		t.Errorf("expected %s, got %v", apperrors.DeadLetterReadError.Code, err)
+	}
+}
@@ -0,0 +1,74 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	// EmailDomainField is the Extra field EmailDomain writes to.
+	EmailDomainField = "email_domain"
+
+	missingDomainError = "email %q has no domain"
+	emptyNameError     = "name is empty after normalization"
+)
+
+// EmailDomain stores the lowercased domain of the user's email in
+// Extra[EmailDomainField].
+type EmailDomain struct{}
+
+func (EmailDomain) Enrich(_ context.Context, user *model.User) error {
+	domain := model.EmailDomain(user.Email)
+	if domain == "" {
+		return apperrors.EnrichUserError.AppendMessage(fmt.Errorf(missingDomainError, user.Email))
+	}
+	return setExtra(user, EmailDomainField, domain)
+}
+
+// NormalizeName trims the user's name, collapses inner whitespace and
+// capitalizes every word, including the parts of hyphenated names.
+type NormalizeName struct{}
+
+func (NormalizeName) Enrich(_ context.Context, user *model.User) error {
+	words := strings.Fields(user.Name)
+	for i, word := range words {
+		words[i] = capitalize(word)
+	}
+	name := strings.Join(words, " ")
+	if name == "" {
+		return apperrors.EnrichUserError.AppendMessage(emptyNameError)
+	}
+	user.Name = name
+	return nil
+}
+
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	start := true
+	for i, r := range runes {
+		if start && unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+		start = r == '-'
+	}
+	return string(runes)
+}
+
+// setExtra stores value as Extra[field], creating the map when needed.
+func setExtra(user *model.User, field string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return apperrors.EnrichUserError.AppendMessage(err)
+	}
+	if user.Extra == nil {
+		user.Extra = make(map[string]json.RawMessage)
+	}
+	user.Extra[field] = data
+	return nil
+}
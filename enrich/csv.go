@@ -0,0 +1,127 @@
+package enrich
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	UserFieldEmail       = "email"
+	UserFieldEmailDomain = "email_domain"
+	UserFieldUsername    = "username"
+	UserFieldID          = "id"
+
+	unknownUserFieldError = "unknown user field %q"
+	missingColumnError    = "column %q not found in %s"
+	typedColumnError      = "column %q would overwrite the typed user field %q"
+	notFoundError         = "no row with %s %q"
+)
+
+// CSVLookup copies the columns of the reference table row matching a user
+// into the user's Extra fields, each named after its column with a prefix.
+type CSVLookup struct {
+	userField string
+	prefix    string
+	columns   []string
+	keyIndex  int
+	rows      map[string][]string
+}
+
+// LoadCSVLookup reads the CSV file at path. Its first row names the columns;
+// rows are matched by keyColumn against userField of each user, ignoring
+// case and surrounding whitespace.
+func LoadCSVLookup(path, keyColumn, userField, prefix string) (*CSVLookup, error) {
+	switch userField {
+	case UserFieldEmail, UserFieldEmailDomain, UserFieldUsername, UserFieldID:
+	default:
+		return nil, apperrors.EnrichConfigError.AppendMessage(fmt.Errorf(unknownUserFieldError, userField))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, apperrors.EnrichLoadError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, apperrors.EnrichLoadError.AppendMessage(err, path)
+	}
+	keyIndex := -1
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		if header[i] == keyColumn {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		return nil, apperrors.EnrichConfigError.AppendMessage(fmt.Errorf(missingColumnError, keyColumn, path))
+	}
+
+	lookup := &CSVLookup{userField: userField, prefix: prefix, columns: header, keyIndex: keyIndex, rows: make(map[string][]string)}
+	for i, column := range header {
+		if i == keyIndex {
+			continue
+		}
+		if field := prefix + column; model.IsUserField(field) {
+			return nil, apperrors.EnrichConfigError.AppendMessage(fmt.Errorf(typedColumnError, column, field))
+		}
+	}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, apperrors.EnrichLoadError.AppendMessage(err, path)
+		}
+		lookup.rows[normalizeKey(row[keyIndex])] = row
+	}
+	return lookup, nil
+}
+
+func (l *CSVLookup) Enrich(_ context.Context, user *model.User) error {
+	key := normalizeKey(l.userKey(user))
+	row, ok := l.rows[key]
+	if !ok {
+		return apperrors.EnrichNotFoundError.AppendMessage(fmt.Errorf(notFoundError, l.userField, key))
+	}
+
+	for i, column := range l.columns {
+		if i == l.keyIndex {
+			continue
+		}
+		if err := setExtra(user, l.prefix+column, row[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *CSVLookup) userKey(user *model.User) string {
+	switch l.userField {
+	case UserFieldEmailDomain:
+		return model.EmailDomain(user.Email)
+	case UserFieldUsername:
+		return user.Username
+	case UserFieldID:
+		return strconv.Itoa(user.ID)
+	default:
+		return user.Email
+	}
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
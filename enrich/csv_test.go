@@ -0,0 +1,65 @@
+package enrich
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "reference.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCSVLookup_Enrich(t *testing.T) {
+	path := writeCSV(t, "domain,segment,region\nexample.com,enterprise,emea\ntest.com,smb,apac\n")
+	lookup, err := LoadCSVLookup(path, "domain", UserFieldEmailDomain, "crm_")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	user := model.User{Name: "Alice", Email: "alice@EXAMPLE.com"}
+	if err := lookup.Enrich(context.Background(), &user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(user.Extra) != 2 || string(user.Extra["crm_segment"]) != `"enterprise"` || string(user.Extra["crm_region"]) != `"emea"` {
+		t.Errorf("unexpected extra fields: %v", user.Extra)
+	}
+
+	err = lookup.Enrich(context.Background(), &model.User{Name: "Bob", Email: "bob@other.org"})
+	if !apperrors.Is(err, apperrors.EnrichNotFoundError) {
+		t.Errorf("expected %s, got %v", apperrors.EnrichNotFoundError.Code, err)
+	}
+}
+
+func TestLoadCSVLookup_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		keyColumn string
+		userField string
+		wantErr   *apperrors.AppError
+	}{
+		{name: "missing key column", content: "email,segment\n", keyColumn: "domain", userField: UserFieldEmail, wantErr: apperrors.EnrichConfigError},
+		{name: "unknown user field", content: "email,segment\n", keyColumn: "email", userField: "phone", wantErr: apperrors.EnrichConfigError},
+		{name: "column shadowing a typed field", content: "email,name\n", keyColumn: "email", userField: UserFieldEmail, wantErr: apperrors.EnrichConfigError},
+		{name: "ragged rows", content: "email,segment\na@b.c,x,y\n", keyColumn: "email", userField: UserFieldEmail, wantErr: apperrors.EnrichLoadError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadCSVLookup(writeCSV(t, tt.content), tt.keyColumn, tt.userField, "")
+			if !apperrors.Is(err, tt.wantErr) {
+				t.Errorf("expected %s, got %v", tt.wantErr.Code, err)
+			}
+		})
+	}
+}
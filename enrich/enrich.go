@@ -0,0 +1,125 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/client"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+// Enricher adds data to a user before it is posted.
+type Enricher interface {
+	Enrich(ctx context.Context, user *model.User) error
+}
+
+// Policy decides what happens to a user when an enricher fails.
+type Policy string
+
+const (
+	// PolicyFail reports the user as failed without posting it.
+	PolicyFail Policy = "fail"
+	// PolicySkip leaves the user out of the run.
+	PolicySkip Policy = "skip"
+	// PolicyContinue posts the user without that enricher's data.
+	PolicyContinue Policy = "continue"
+
+	EmailDomainName   = "email_domain"
+	NormalizeNameName = "normalize_name"
+	CSVLookupName     = "csv_lookup"
+	HTTPLookupName    = "http_lookup"
+
+	unknownEnricherError = "unknown enricher %q"
+	unknownPolicyError   = "unknown policy %q for enricher %q"
+	enricherError        = "%s: %w"
+)
+
+// ErrSkipped is returned by Chain.Enrich for users that a failing enricher
+// with PolicySkip left out.
+var ErrSkipped = errors.New("user skipped by enricher")
+
+// Stage is an Enricher of a Chain together with its error policy.
+type Stage struct {
+	Name     string
+	Enricher Enricher
+	Policy   Policy
+}
+
+// Chain runs its stages in order.
+type Chain struct {
+	stages []Stage
+}
+
+func NewChain(stages ...Stage) *Chain {
+	return &Chain{stages: stages}
+}
+
+// Enrich runs every stage on user. Failures of PolicyContinue stages are
+// returned as warnings and the chain goes on. A PolicySkip failure stops the
+// chain with an error wrapping ErrSkipped, and a PolicyFail failure stops it
+// with the enricher's error.
+func (c *Chain) Enrich(ctx context.Context, user *model.User) (warnings []error, err error) {
+	for _, stage := range c.stages {
+		stageErr := stage.Enricher.Enrich(ctx, user)
+		if stageErr == nil {
+			continue
+		}
+		stageErr = fmt.Errorf(enricherError, stage.Name, stageErr)
+		switch stage.Policy {
+		case PolicyContinue:
+			warnings = append(warnings, stageErr)
+		case PolicySkip:
+			return warnings, fmt.Errorf("%w: %w", ErrSkipped, stageErr)
+		default:
+			return warnings, stageErr
+		}
+	}
+	return warnings, nil
+}
+
+// FromConfig builds the chain listed in cfg.Enrichers. Each entry is an
+// enricher name optionally followed by ":" and its policy, which defaults to
+// PolicyFail. clientOpts are passed to the client of the http_lookup
+// enricher, to instrument it like the dispatch client.
+func FromConfig(cfg *config.Config, clientOpts ...client.Option) (*Chain, error) {
+	stages := make([]Stage, 0, len(cfg.Enrichers))
+	for _, entry := range cfg.Enrichers {
+		name, policy, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		stage := Stage{Name: name, Policy: Policy(policy)}
+		switch stage.Policy {
+		case "":
+			stage.Policy = PolicyFail
+		case PolicyFail, PolicySkip, PolicyContinue:
+		default:
+			return nil, apperrors.EnrichConfigError.AppendMessage(fmt.Errorf(unknownPolicyError, policy, name))
+		}
+
+		switch name {
+		case EmailDomainName:
+			stage.Enricher = EmailDomain{}
+		case NormalizeNameName:
+			stage.Enricher = NormalizeName{}
+		case CSVLookupName:
+			lookup, err := LoadCSVLookup(cfg.EnrichCSVPath, cfg.EnrichCSVKeyColumn, cfg.EnrichCSVUserField, cfg.EnrichCSVPrefix)
+			if err != nil {
+				return nil, err
+			}
+			stage.Enricher = lookup
+		case HTTPLookupName:
+			lookup, err := NewHTTPLookup(client.NewLookupClient(cfg, clientOpts...), cfg.EnrichHTTPURL, cfg.EnrichHTTPFields, cfg.EnrichHTTPPrefix, cfg.EnrichHTTPCacheSize, cfg.EnrichHTTPCacheTTL)
+			if err != nil {
+				return nil, err
+			}
+			stage.Enricher = lookup
+		default:
+			return nil, apperrors.EnrichConfigError.AppendMessage(fmt.Errorf(unknownEnricherError, name))
+		}
+		stages = append(stages, stage)
+	}
+
+	return NewChain(stages...), nil
+}
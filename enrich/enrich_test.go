@@ -0,0 +1,116 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+type enricherFunc func(ctx context.Context, user *model.User) error
+
+func (f enricherFunc) Enrich(ctx context.Context, user *model.User) error {
+	return f(ctx, user)
+}
+
+func TestChain_Enrich(t *testing.T) {
+	boom := errors.New("boom")
+	failing := enricherFunc(func(context.Context, *model.User) error { return boom })
+	rename := enricherFunc(func(_ context.Context, user *model.User) error {
+		user.Name = "renamed"
+		return nil
+	})
+
+	tests := []struct {
+		name         string
+		policy       Policy
+		wantWarnings int
+		wantSkipped  bool
+		wantErr      bool
+		wantName     string
+	}{
+		{name: "continue", policy: PolicyContinue, wantWarnings: 1, wantName: "renamed"},
+		{name: "skip", policy: PolicySkip, wantSkipped: true, wantErr: true, wantName: "Alice"},
+		{name: "fail", policy: PolicyFail, wantErr: true, wantName: "Alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := NewChain(Stage{Name: "failing", Enricher: failing, Policy: tt.policy}, Stage{Name: "rename", Enricher: rename, Policy: PolicyFail})
+			user := model.User{Name: "Alice", Email: "alice@example.com"}
+			warnings, err := chain.Enrich(context.Background(), &user)
+
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.wantWarnings, warnings)
+			}
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrSkipped) != tt.wantSkipped {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err != nil && !errors.Is(err, boom) {
+				t.Errorf("expected the enricher error to be kept, got %v", err)
+			}
+			if user.Name != tt.wantName {
+				t.Errorf("expected name %q, got %q", tt.wantName, user.Name)
+			}
+		})
+	}
+}
+
+func TestEmailDomain(t *testing.T) {
+	user := model.User{Name: "Alice", Email: "alice@Example.COM"}
+	if err := (EmailDomain{}).Enrich(context.Background(), &user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(user.Extra[EmailDomainField]); got != `"example.com"` {
+		t.Errorf("expected the lowercased domain, got %s", got)
+	}
+
+	err := (EmailDomain{}).Enrich(context.Background(), &model.User{Email: "alice"})
+	if !apperrors.Is(err, apperrors.EnrichUserError) {
+		t.Errorf("expected %s, got %v", apperrors.EnrichUserError.Code, err)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "  JOHN   doe ", want: "John Doe"},
+		{name: "mary-jane o'neil", want: "Mary-Jane O'neil"},
+		{name: "élodie", want: "Élodie"},
+	}
+
+	for _, tt := range tests {
+		user := model.User{Name: tt.name}
+		if err := (NormalizeName{}).Enrich(context.Background(), &user); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if user.Name != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, user.Name, tt.want)
+		}
+	}
+
+	if err := (NormalizeName{}).Enrich(context.Background(), &model.User{Name: "  "}); err == nil {
+		t.Errorf("expected an error for a blank name")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	chain, err := FromConfig(&config.Config{Enrichers: []string{"normalize_name", " email_domain:continue"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chain.stages) != 2 || chain.stages[0].Policy != PolicyFail || chain.stages[1].Policy != PolicyContinue {
+		t.Errorf("unexpected stages: %+v", chain.stages)
+	}
+
+	for _, enrichers := range [][]string{{"geoip"}, {"email_domain:retry"}} {
+		if _, err := FromConfig(&config.Config{Enrichers: enrichers}); !apperrors.Is(err, apperrors.EnrichConfigError) {
+			t.Errorf("expected %s for %v, got %v", apperrors.EnrichConfigError.Code, enrichers, err)
+		}
+	}
+}
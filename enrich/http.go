@@ -0,0 +1,183 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/client"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	placeholderName        = "name"
+	fieldPathSeparator     = "."
+	fieldTargetSeparator   = ":"
+	unknownPlaceholderErr  = "unknown placeholder {%s} in %q"
+	missingPlaceholderErr  = "user has no %s for %q"
+	missingHTTPFieldsError = "at least one response field must be selected"
+	invalidFieldError      = "invalid response field %q"
+	notObjectResponseError = "response of %s is not a JSON object"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Getter fetches the document at a URL. *client.LookupClient implements it.
+type Getter interface {
+	Get(ctx context.Context, targetURL string) ([]byte, error)
+}
+
+type responseField struct {
+	path   []string
+	target string
+}
+
+// HTTPLookup merges fields of a JSON document fetched from a side API into
+// the user's Extra fields. The URL is built from a template whose
+// placeholders ({email}, {email_domain}, {username}, {id}, {name}) are
+// replaced with the path-escaped user values. Results, including documents
+// that were not found, are cached per URL.
+type HTTPLookup struct {
+	getter   Getter
+	template string
+	fields   []responseField
+	cache    *lruCache
+}
+
+// NewHTTPLookup checks the URL template and the selected fields, each given
+// as a dotted response path optionally followed by ":" and the Extra field
+// it is stored in. Targets default to the last path segment, and prefix is
+// put in front of every target.
+func NewHTTPLookup(getter Getter, urlTemplate string, fields []string, prefix string, cacheSize int, cacheTTL time.Duration) (*HTTPLookup, error) {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(urlTemplate, -1) {
+		switch match[1] {
+		case UserFieldEmail, UserFieldEmailDomain, UserFieldUsername, UserFieldID, placeholderName:
+		default:
+			return nil, apperrors.EnrichConfigError.AppendMessage(fmt.Errorf(unknownPlaceholderErr, match[1], urlTemplate))
+		}
+	}
+	if _, err := url.Parse(placeholderPattern.ReplaceAllString(urlTemplate, "x")); err != nil || urlTemplate == "" {
+		return nil, apperrors.EnrichConfigError.AppendMessage(err, urlTemplate)
+	}
+	if len(fields) == 0 {
+		return nil, apperrors.EnrichConfigError.AppendMessage(missingHTTPFieldsError)
+	}
+
+	lookup := &HTTPLookup{getter: getter, template: urlTemplate, cache: newLRUCache(cacheSize, cacheTTL)}
+	for _, field := range fields {
+		path, target, _ := strings.Cut(strings.TrimSpace(field), fieldTargetSeparator)
+		segments := strings.Split(path, fieldPathSeparator)
+		if target == "" {
+			target = segments[len(segments)-1]
+		}
+		target = prefix + target
+		if path == "" || target == "" || model.IsUserField(target) {
+			return nil, apperrors.EnrichConfigError.AppendMessage(fmt.Errorf(invalidFieldError, field))
+		}
+		lookup.fields = append(lookup.fields, responseField{path: segments, target: target})
+	}
+
+	return lookup, nil
+}
+
+func (l *HTTPLookup) Enrich(ctx context.Context, user *model.User) error {
+	targetURL, err := l.url(user)
+	if err != nil {
+		return err
+	}
+
+	result, ok := l.cache.get(targetURL)
+	if !ok {
+		if result, err = l.fetch(ctx, targetURL); err != nil {
+			return err
+		}
+		l.cache.add(targetURL, result)
+	}
+	if result.notFound {
+		return apperrors.EnrichNotFoundError.AppendMessage(targetURL)
+	}
+
+	for target, value := range result.fields {
+		if user.Extra == nil {
+			user.Extra = make(map[string]json.RawMessage, len(result.fields))
+		}
+		user.Extra[target] = value
+	}
+	return nil
+}
+
+func (l *HTTPLookup) url(user *model.User) (string, error) {
+	var err error
+	rendered := placeholderPattern.ReplaceAllStringFunc(l.template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		var value string
+		switch name {
+		case UserFieldEmail:
+			value = strings.TrimSpace(user.Email)
+		case UserFieldEmailDomain:
+			value = model.EmailDomain(user.Email)
+		case UserFieldUsername:
+			value = user.Username
+		case UserFieldID:
+			if user.ID != 0 {
+				value = strconv.Itoa(user.ID)
+			}
+		case placeholderName:
+			value = user.Name
+		}
+		if value == "" && err == nil {
+			err = apperrors.EnrichUserError.AppendMessage(fmt.Errorf(missingPlaceholderErr, name, l.template))
+		}
+		return url.PathEscape(value)
+	})
+	return rendered, err
+}
+
+// fetch gets targetURL and extracts the selected fields. A 404 is a result
+// rather than an error, so it can be cached.
+func (l *HTTPLookup) fetch(ctx context.Context, targetURL string) (lookupResult, error) {
+	body, err := l.getter.Get(ctx, targetURL)
+	var deliveryErr *client.DeliveryError
+	if errors.As(err, &deliveryErr) && deliveryErr.StatusCode == http.StatusNotFound {
+		return lookupResult{notFound: true}, nil
+	}
+	if err != nil {
+		return lookupResult{}, apperrors.EnrichUserError.AppendMessage(err)
+	}
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(body, &document); err != nil {
+		return lookupResult{}, apperrors.EnrichUserError.AppendMessage(fmt.Errorf(notObjectResponseError, targetURL), err)
+	}
+
+	result := lookupResult{fields: make(map[string]json.RawMessage, len(l.fields))}
+	for _, field := range l.fields {
+		if value, ok := lookupPath(document, field.path); ok {
+			result.fields[field.target] = value
+		}
+	}
+	return result, nil
+}
+
+func lookupPath(document map[string]json.RawMessage, path []string) (json.RawMessage, bool) {
+	value, ok := document[path[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(path) == 1 {
+		return value, true
+	}
+	var child map[string]json.RawMessage
+	if err := json.Unmarshal(value, &child); err != nil {
+		return nil, false
+	}
+	return lookupPath(child, path[1:])
+}
@@ -0,0 +1,127 @@
+package enrich
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/client"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+func TestHTTPLookup_Enrich(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/companies/example.com":
+			//nolint:errcheck
+			w.Write([]byte(`{"name":"Example Inc","size":250,"address":{"country":"NL"},"internal":"x"}`))
+		case "/companies/flaky.com":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	getter := client.NewLookupClient(&config.Config{RetryMaxAttempts: 2, RetryInitialInterval: time.Millisecond})
+	lookup, err := NewHTTPLookup(getter, server.URL+"/companies/{email_domain}", []string{"name:company_name", "size", "address.country", "missing"}, "co_", 10, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		user := model.User{Name: "Alice", Email: "alice@Example.com"}
+		if err := lookup.Enrich(context.Background(), &user); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(user.Extra) != 3 || string(user.Extra["co_company_name"]) != `"Example Inc"` ||
+			string(user.Extra["co_size"]) != "250" || string(user.Extra["co_country"]) != `"NL"` {
+			t.Errorf("unexpected extra fields: %v", user.Extra)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected the second lookup to be served from the cache, got %d requests", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := lookup.Enrich(context.Background(), &model.User{Name: "Bob", Email: "bob@unknown.org"})
+		if !apperrors.Is(err, apperrors.EnrichNotFoundError) {
+			t.Errorf("expected %s, got %v", apperrors.EnrichNotFoundError.Code, err)
+		}
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("expected a not found document to be cached, got %d requests", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := lookup.Enrich(context.Background(), &model.User{Name: "Carol", Email: "carol@flaky.com"})
+		if !apperrors.Is(err, apperrors.EnrichUserError) || !apperrors.Is(err, apperrors.ApiClientLookupError) {
+			t.Errorf("expected %s, got %v", apperrors.EnrichUserError.Code, err)
+		}
+	}
+	if got := requests.Load(); got != 6 {
+		t.Errorf("expected failures to be retried and never cached, got %d requests", got)
+	}
+
+	err = lookup.Enrich(context.Background(), &model.User{Name: "Dave", Email: "dave"})
+	if !apperrors.Is(err, apperrors.EnrichUserError) {
+		t.Errorf("expected %s for a user without a domain, got %v", apperrors.EnrichUserError.Code, err)
+	}
+}
+
+func TestNewHTTPLookup_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		fields   []string
+	}{
+		{name: "unknown placeholder", template: "http://api/{phone}", fields: []string{"name"}},
+		{name: "empty template", template: "", fields: []string{"name"}},
+		{name: "no fields", template: "http://api/{email}"},
+		{name: "target shadowing a typed field", template: "http://api/{email}", fields: []string{"company.name:website"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPLookup(nil, tt.template, tt.fields, "", 1, time.Minute)
+			if !apperrors.Is(err, apperrors.EnrichConfigError) {
+				t.Errorf("expected %s, got %v", apperrors.EnrichConfigError.Code, err)
+			}
+		})
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	now := time.Now()
+	cache := newLRUCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.add("a", lookupResult{notFound: true})
+	cache.add("b", lookupResult{notFound: true})
+	cache.get("a")
+	cache.add("c", lookupResult{notFound: true})
+	if _, ok := cache.get("b"); ok {
+		t.Errorf("expected the least recently used entry to be evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Errorf("expected a recently used entry to be kept")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("c"); ok {
+		t.Errorf("expected entries to expire after the ttl")
+	}
+
+	var disabled *lruCache
+	disabled.add("a", lookupResult{})
+	if _, ok := disabled.get("a"); ok {
+		t.Errorf("expected a nil cache to cache nothing")
+	}
+}
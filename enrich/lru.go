@@ -0,0 +1,88 @@
+package enrich
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// lookupResult is what a lookup yielded for one URL.
+type lookupResult struct {
+	fields   map[string]json.RawMessage
+	notFound bool
+}
+
+type cacheEntry struct {
+	key       string
+	result    lookupResult
+	expiresAt time.Time
+}
+
+// lruCache keeps up to size lookup results for ttl each, evicting the least
+// recently used one when full. A nil *lruCache caches nothing.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	now   func() time.Time
+	items map[string]*list.Element
+	order *list.List
+}
+
+// newLRUCache returns nil, caching nothing, when size or ttl is not positive.
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &lruCache{
+		size:  size,
+		ttl:   ttl,
+		now:   time.Now,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+func (c *lruCache) get(key string) (lookupResult, bool) {
+	if c == nil {
+		return lookupResult{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return lookupResult{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return lookupResult{}, false
+	}
+	c.order.MoveToFront(element)
+	return entry.result, true
+}
+
+func (c *lruCache) add(key string, result lookupResult) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.result, entry.expiresAt = result, expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, result: result, expiresAt: expiresAt})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
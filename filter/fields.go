@@ -0,0 +1,92 @@
+package filter
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"data-enricher-dispatcher/model"
+)
+
+const extraFieldPrefix = "extra."
+
+// accessor reads a field of a user. It reports false when the user has no
+// value for it, which no comparison matches.
+type accessor func(user *model.User) (string, bool)
+
+func present(value string) (string, bool) {
+	return value, value != ""
+}
+
+var fields = map[string]accessor{
+	"id": func(u *model.User) (string, bool) {
+		if u.ID == 0 {
+			return "", false
+		}
+		return strconv.Itoa(u.ID), true
+	},
+	"name":            func(u *model.User) (string, bool) { return present(u.Name) },
+	"username":        func(u *model.User) (string, bool) { return present(u.Username) },
+	"email":           func(u *model.User) (string, bool) { return present(u.Email) },
+	"email_domain":    func(u *model.User) (string, bool) { return present(model.EmailDomain(u.Email)) },
+	"phone":           func(u *model.User) (string, bool) { return present(u.Phone) },
+	"website":         func(u *model.User) (string, bool) { return present(u.Website) },
+	"address.street":  addressField(func(a *model.Address) string { return a.Street }),
+	"address.suite":   addressField(func(a *model.Address) string { return a.Suite }),
+	"address.city":    addressField(func(a *model.Address) string { return a.City }),
+	"address.zipcode": addressField(func(a *model.Address) string { return a.Zipcode }),
+	"address.geo.lat": addressField(func(a *model.Address) string {
+		if a.Geo == nil {
+			return ""
+		}
+		return a.Geo.Lat
+	}),
+	"address.geo.lng": addressField(func(a *model.Address) string {
+		if a.Geo == nil {
+			return ""
+		}
+		return a.Geo.Lng
+	}),
+	"company.name":        companyField(func(c *model.Company) string { return c.Name }),
+	"company.catchPhrase": companyField(func(c *model.Company) string { return c.CatchPhrase }),
+	"company.bs":          companyField(func(c *model.Company) string { return c.BS }),
+}
+
+func addressField(get func(*model.Address) string) accessor {
+	return func(u *model.User) (string, bool) {
+		if u.Address == nil {
+			return "", false
+		}
+		return present(get(u.Address))
+	}
+}
+
+func companyField(get func(*model.Company) string) accessor {
+	return func(u *model.User) (string, bool) {
+		if u.Company == nil {
+			return "", false
+		}
+		return present(get(u.Company))
+	}
+}
+
+// lookupField returns the accessor of name. Extra fields are addressed as
+// "extra.<key>"; JSON strings are compared unquoted and other values by
+// their JSON text.
+func lookupField(name string) (accessor, bool) {
+	if key, ok := strings.CutPrefix(name, extraFieldPrefix); ok && key != "" {
+		return func(u *model.User) (string, bool) {
+			raw, ok := u.Extra[key]
+			if !ok {
+				return "", false
+			}
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				return s, true
+			}
+			return string(raw), true
+		}, true
+	}
+	get, ok := fields[name]
+	return get, ok
+}
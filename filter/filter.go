@@ -0,0 +1,73 @@
+package filter
+
+import (
+	"fmt"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	notIncludedReason = "matches no include rule"
+	excludedReason    = "matches exclude rule %d"
+)
+
+// Filter decides which users are dispatched from include and exclude rule
+// lists written in a small expression language:
+//
+//	email endsWith ".biz" and not name matches "^Test"
+//	email domain "example.com" or company.name in ["Acme", "Initech"]
+//	id >= 100; extra.segment == "vip"
+//
+// Rules are separated by ";". Comparisons take a field, an operator (==, !=,
+// <, <=, >, >=, contains, startsWith, endsWith, matches, domain, in) and a
+// literal, and combine with and, or, not and parentheses. Fields are the
+// JSON names of model.User, dotted for nested ones, plus email_domain and
+// extra.<key>. A field the user has no value for matches nothing.
+type Filter struct {
+	include []node
+	exclude []node
+}
+
+// New compiles the include and exclude rules. Syntax errors, unknown fields
+// or operators and invalid regular expressions are all reported here, so a
+// Filter never fails while evaluating.
+func New(include, exclude string) (*Filter, error) {
+	includeRules, err := parseRules(include)
+	if err != nil {
+		return nil, apperrors.FilterParseError.AppendMessage(fmt.Errorf("include: %w", err))
+	}
+	excludeRules, err := parseRules(exclude)
+	if err != nil {
+		return nil, apperrors.FilterParseError.AppendMessage(fmt.Errorf("exclude: %w", err))
+	}
+	return &Filter{include: includeRules, exclude: excludeRules}, nil
+}
+
+// Empty reports whether the filter has no rules and so allows every user.
+func (f *Filter) Empty() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// Allows reports whether user matches an include rule, or there are none,
+// and no exclude rule. When it does not, reason says why.
+func (f *Filter) Allows(user *model.User) (allowed bool, reason string) {
+	if len(f.include) > 0 && !anyMatch(f.include, user) {
+		return false, notIncludedReason
+	}
+	for i, rule := range f.exclude {
+		if rule.eval(user) {
+			return false, fmt.Sprintf(excludedReason, i+1)
+		}
+	}
+	return true, ""
+}
+
+func anyMatch(rules []node, user *model.User) bool {
+	for _, rule := range rules {
+		if rule.eval(user) {
+			return true
+		}
+	}
+	return false
+}
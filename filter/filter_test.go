@@ -0,0 +1,120 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+func TestFilter_Allows(t *testing.T) {
+	leanne := &model.User{
+		ID:      1,
+		Name:    "Leanne Graham",
+		Email:   "Sincere@April.biz",
+		Company: &model.Company{Name: "Romaguera-Crona"},
+		Address: &model.Address{City: "Gwenborough", Geo: &model.Geo{Lat: "-37.3159"}},
+		Extra:   map[string]json.RawMessage{"segment": json.RawMessage(`"vip"`), "score": json.RawMessage(`42`)},
+	}
+	tester := &model.User{ID: 11, Name: "Test User", Email: "test@mail.example.com"}
+	quoted := &model.User{ID: 12, Name: "Quoted User", Email: `"a@b"@Example.com`}
+
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		user    *model.User
+		want    bool
+	}{
+		{name: "no rules", user: leanne, want: true},
+		{name: "endsWith and not matches", include: `email endsWith ".biz" and not name matches "^Test"`, user: leanne, want: true},
+		{name: "not matches excludes", include: `email endsWith ".com" and not name matches "^Test"`, user: tester, want: false},
+		{name: "exclude wins", include: `id > 0`, exclude: `name startsWith "Test"`, user: tester, want: false},
+		{name: "any include rule", include: `id == 99; company.name contains "Crona"`, user: leanne, want: true},
+		{name: "no include rule", include: `id == 99; company.name contains "Acme"`, user: leanne, want: false},
+		{name: "domain is case insensitive", include: `email domain "april.BIZ"`, user: leanne, want: true},
+		{name: "subdomain", include: `email domain "example.com"`, user: tester, want: true},
+		{name: "domain boundary", include: `email domain "ample.com"`, user: tester, want: false},
+		{name: "email_domain field", include: `email_domain == "april.biz"`, user: leanne, want: true},
+		{name: "email_domain after the last @", include: `email_domain == "example.com"`, user: quoted, want: true},
+		{name: "numeric comparison", include: `id >= 10 and id < 12`, user: tester, want: true},
+		{name: "nested numeric field", include: `address.geo.lat < -30`, user: leanne, want: true},
+		{name: "in list", include: `address.city in ["Wisokyburgh", "Gwenborough"]`, user: leanne, want: true},
+		{name: "in numbers", include: `id in [1, 2, 3]`, user: tester, want: false},
+		{name: "extra string", include: `extra.segment == "vip"`, user: leanne, want: true},
+		{name: "extra number", include: `extra.score > 40`, user: leanne, want: true},
+		{name: "missing field matches nothing", include: `company.name != "Acme"`, user: tester, want: false},
+		{name: "parentheses and or", include: `(id == 11 or id == 1) and not (email domain "biz")`, user: leanne, want: false},
+		{name: "keywords ignore case", include: `NOT name STARTSWITH "Test" AND id == 1`, user: leanne, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, reason := f.Allows(tt.user); got != tt.want {
+				t.Errorf("Allows() = %v (%s), want %v", got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		include string
+	}{
+		{name: "unknown field", include: `phone_number == "1"`},
+		{name: "unknown operator", include: `name like "A%"`},
+		{name: "invalid regex", include: `name matches "("`},
+		{name: "ordering a string", include: `id > "10"`},
+		{name: "matching a number", include: `name contains 1`},
+		{name: "unterminated string", include: `name == "Alice`},
+		{name: "missing literal", include: `name ==`},
+		{name: "unbalanced parentheses", include: `(name == "Alice"`},
+		{name: "dangling operator", include: `name == "Alice" and`},
+		{name: "missing separator", include: `name == "Alice" id == 1`},
+		{name: "bad list", include: `id in [1 2]`},
+		{name: "empty domain", include: `email domain "."`},
+		{name: "stray character", include: `name == "Alice" & id == 1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.include, ""); !apperrors.Is(err, apperrors.FilterParseError) {
+				t.Errorf("expected %s, got %v", apperrors.FilterParseError.Code, err)
+			}
+			if _, err := New("", tt.include); !apperrors.Is(err, apperrors.FilterParseError) {
+				t.Errorf("expected %s for exclude rules, got %v", apperrors.FilterParseError.Code, err)
+			}
+		})
+	}
+}
+
+func TestFilter_Empty(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude string
+		expected         bool
+	}{
+		{name: "no rules", expected: true},
+		{name: "blank rules", include: "  ", exclude: "\n", expected: true},
+		{name: "include rule", include: `id == 1`},
+		{name: "exclude rule", exclude: `id == 1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if f.Empty() != tt.expected {
+				t.Errorf("expected Empty to be %v", tt.expected)
+			}
+		})
+	}
+}
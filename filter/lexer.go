@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenSemicolon
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+const (
+	unterminatedStringError = "unterminated string at %d"
+	unexpectedCharError     = "unexpected %q at %d"
+)
+
+// lex splits src into tokens. Identifiers cover field names and keywords,
+// string literals use Go syntax and are unquoted into value.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '`':
+			end := i + 1
+			for end < len(src) && rune(src[end]) != c {
+				if src[end] == '\\' && c == '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf(unterminatedStringError, i)
+			}
+			value, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w at %d", err, i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: src[i : end+1], value: value, pos: i})
+			i = end + 1
+		case c == '-' || unicode.IsDigit(c):
+			end := i + 1
+			for end < len(src) && (unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+			if _, err := strconv.ParseFloat(src[i:end], 64); err != nil {
+				return nil, fmt.Errorf(unexpectedCharError, src[i:end], i)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[i:end], value: src[i:end], pos: i})
+			i = end
+		case unicode.IsLetter(c) || c == '_':
+			end := i + 1
+			for end < len(src) && isIdentChar(rune(src[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[i:end], value: src[i:end], pos: i})
+			i = end
+		case strings.ContainsRune("=!<>", c):
+			end := i + 1
+			if end < len(src) && src[end] == '=' {
+				end++
+			}
+			op := src[i:end]
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf(unexpectedCharError, op, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, value: op, pos: i})
+			i = end
+		default:
+			kind, ok := punctuation[c]
+			if !ok {
+				return nil, fmt.Errorf(unexpectedCharError, c, i)
+			}
+			tokens = append(tokens, token{kind: kind, text: string(c), pos: i})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+var punctuation = map[rune]tokenKind{
+	'(': tokenLParen,
+	')': tokenRParen,
+	'[': tokenLBracket,
+	']': tokenRBracket,
+	',': tokenComma,
+	';': tokenSemicolon,
+}
+
+func isIdentChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.'
+}
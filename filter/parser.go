@@ -0,0 +1,309 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	unexpectedTokenError = "unexpected %s at %d, expected %s"
+	unknownFieldError    = "unknown field %q at %d"
+	unknownOperatorError = "unknown operator %q at %d"
+	invalidRegexError    = "invalid regular expression at %d: %v"
+	numberExpectedError  = "operator %s at %d needs a number"
+	stringExpectedError  = "operator %s at %d needs a string"
+	emptyDomainError     = "empty domain at %d"
+)
+
+type node interface {
+	eval(user *model.User) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(user *model.User) bool { return n.left.eval(user) && n.right.eval(user) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(user *model.User) bool { return n.left.eval(user) || n.right.eval(user) }
+
+type notNode struct{ inner node }
+
+func (n notNode) eval(user *model.User) bool { return !n.inner.eval(user) }
+
+// compareNode matches a field value. Missing values never match.
+type compareNode struct {
+	field accessor
+	match func(value string) bool
+}
+
+func (n compareNode) eval(user *model.User) bool {
+	value, ok := n.field(user)
+	return ok && n.match(value)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parseRules parses the ";" separated expressions of src. Empty rules are
+// ignored, so a trailing separator is allowed.
+func parseRules(src string) ([]node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	var rules []node
+	for p.peek().kind != tokenEOF {
+		if p.peek().kind == tokenSemicolon {
+			p.next()
+			continue
+		}
+		rule, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if kind := p.peek().kind; kind != tokenSemicolon && kind != tokenEOF {
+			return nil, p.unexpected(`";", "and" or "or"`)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokenIdent && strings.EqualFold(t.text, word) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	found := strconv.Quote(t.text)
+	if t.kind == tokenEOF {
+		found = "end of rule"
+	}
+	return fmt.Errorf(unexpectedTokenError, found, t.pos, expected)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.keyword("not") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	if p.peek().kind == tokenLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, p.unexpected(`")"`)
+		}
+		p.next()
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	fieldToken := p.peek()
+	if fieldToken.kind != tokenIdent {
+		return nil, p.unexpected("a field")
+	}
+	p.next()
+	field, ok := lookupField(fieldToken.text)
+	if !ok {
+		return nil, fmt.Errorf(unknownFieldError, fieldToken.text, fieldToken.pos)
+	}
+
+	opToken := p.next()
+	if opToken.kind != tokenOperator && opToken.kind != tokenIdent {
+		p.pos--
+		return nil, p.unexpected("an operator")
+	}
+	op := strings.ToLower(opToken.text)
+
+	if op == "in" {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{field: field, match: func(value string) bool {
+			for _, candidate := range values {
+				if equal(value, candidate) {
+					return true
+				}
+			}
+			return false
+		}}, nil
+	}
+
+	literal := p.next()
+	if literal.kind != tokenString && literal.kind != tokenNumber {
+		p.pos--
+		return nil, p.unexpected("a string or number")
+	}
+	match, err := compileMatch(op, opToken.pos, literal)
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{field: field, match: match}, nil
+}
+
+func (p *parser) parseList() ([]string, error) {
+	if p.peek().kind != tokenLBracket {
+		return nil, p.unexpected(`"["`)
+	}
+	p.next()
+	var values []string
+	for {
+		literal := p.next()
+		if literal.kind != tokenString && literal.kind != tokenNumber {
+			p.pos--
+			return nil, p.unexpected("a string or number")
+		}
+		values = append(values, literal.value)
+		switch p.next().kind {
+		case tokenComma:
+		case tokenRBracket:
+			return values, nil
+		default:
+			p.pos--
+			return nil, p.unexpected(`"," or "]"`)
+		}
+	}
+}
+
+func compileMatch(op string, pos int, literal token) (func(string) bool, error) {
+	want := literal.value
+	switch op {
+	case "==":
+		return func(value string) bool { return equal(value, want) }, nil
+	case "!=":
+		return func(value string) bool { return !equal(value, want) }, nil
+	case "<", "<=", ">", ">=":
+		if literal.kind != tokenNumber {
+			return nil, fmt.Errorf(numberExpectedError, op, pos)
+		}
+		bound, _ := strconv.ParseFloat(want, 64)
+		return func(value string) bool {
+			n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return false
+			}
+			switch op {
+			case "<":
+				return n < bound
+			case "<=":
+				return n <= bound
+			case ">":
+				return n > bound
+			default:
+				return n >= bound
+			}
+		}, nil
+	}
+
+	if literal.kind != tokenString {
+		return nil, fmt.Errorf(stringExpectedError, op, pos)
+	}
+	switch op {
+	case "contains":
+		return func(value string) bool { return strings.Contains(value, want) }, nil
+	case "startswith":
+		return func(value string) bool { return strings.HasPrefix(value, want) }, nil
+	case "endswith":
+		return func(value string) bool { return strings.HasSuffix(value, want) }, nil
+	case "matches":
+		re, err := regexp.Compile(want)
+		if err != nil {
+			return nil, fmt.Errorf(invalidRegexError, pos, err)
+		}
+		return re.MatchString, nil
+	case "domain":
+		domain := strings.ToLower(strings.Trim(strings.TrimSpace(want), "."))
+		if domain == "" {
+			return nil, fmt.Errorf(emptyDomainError, pos)
+		}
+		return func(value string) bool { return inDomain(value, domain) }, nil
+	}
+	return nil, fmt.Errorf(unknownOperatorError, op, pos)
+}
+
+// equal compares numerically when both sides are numbers, so that id == 1
+// matches "1" as well as "1.0".
+func equal(value, want string) bool {
+	a, errA := strconv.ParseFloat(value, 64)
+	b, errB := strconv.ParseFloat(want, 64)
+	if errA == nil && errB == nil {
+		return a == b
+	}
+	return value == want
+}
+
+// inDomain reports whether the host of value, the part after the last "@"
+// for email addresses, is domain or one of its subdomains. Matching stops at
+// label boundaries, so "notexample.com" is not in "example.com".
+func inDomain(value, domain string) bool {
+	if at := strings.LastIndex(value, "@"); at >= 0 {
+		value = value[at+1:]
+	}
+	host := strings.ToLower(strings.Trim(strings.TrimSpace(value), "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,129 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+)
+
+const malformedRecord = "line %d: %v"
+
+// Ledger remembers the content hash of the payload last delivered for every
+// user, keyed by the user's key. Implementations must be safe for concurrent
+// use.
+type Ledger interface {
+	// Has reports whether hash is the content last delivered for key.
+	Has(key, hash string) bool
+	// Record marks hash as the content last delivered for key.
+	Record(key, hash string) error
+	Close() error
+}
+
+// record is a line of the ledger file. Later lines for a key replace earlier
+// ones. Lines without a hash, written when the ledger only held content
+// hashes, are ignored, so those users are delivered once more.
+type record struct {
+	Key         string    `json:"key"`
+	Hash        string    `json:"hash,omitempty"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
+
+// fileLedger keeps the last hash of every key in memory and appends changes
+// to a JSONL file, so a ledger survives restarts without an external
+// database.
+type fileLedger struct {
+	mu     sync.RWMutex
+	hashes map[string]string
+	file   *os.File
+}
+
+// Open loads the ledger stored at path, creating it if it does not exist.
+func Open(path string) (Ledger, error) {
+	hashes, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, apperrors.LedgerOpenError.AppendMessage(err)
+	}
+
+	return &fileLedger{hashes: hashes, file: file}, nil
+}
+
+func load(path string) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, apperrors.LedgerOpenError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, apperrors.LedgerReadError.AppendMessage(fmt.Errorf(malformedRecord, line, err))
+		}
+		if rec.Hash != "" {
+			hashes[rec.Key] = rec.Hash
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, apperrors.LedgerReadError.AppendMessage(err)
+	}
+
+	return hashes, nil
+}
+
+func (l *fileLedger) Has(key, hash string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	stored, ok := l.hashes[key]
+	return ok && stored == hash
+}
+
+func (l *fileLedger) Record(key, hash string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if stored, ok := l.hashes[key]; ok && stored == hash {
+		return nil
+	}
+	data, err := json.Marshal(record{Key: key, Hash: hash, DeliveredAt: time.Now().UTC()})
+	if err != nil {
+		return apperrors.LedgerWriteError.AppendMessage(err)
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return apperrors.LedgerWriteError.AppendMessage(err)
+	}
+	l.hashes[key] = hash
+
+	return nil
+}
+
+func (l *fileLedger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.file.Close(); err != nil {
+		return apperrors.LedgerCloseError.AppendMessage(err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+)
+
+func TestFileLedger_PersistsAcrossOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected open error: %v", err)
+	}
+	if l.Has("a", "1") {
+		t.Errorf("expected an empty ledger")
+	}
+	for _, rec := range []record{{Key: "a", Hash: "1"}, {Key: "b", Hash: "1"}, {Key: "a", Hash: "1"}, {Key: "a", Hash: "2"}} {
+		if err := l.Record(rec.Key, rec.Hash); err != nil {
+			t.Fatalf("unexpected record error: %v", err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("unexpected reopen error: %v", err)
+	}
+	defer l.Close()
+	if !l.Has("a", "2") || !l.Has("b", "1") || l.Has("c", "1") {
+		t.Errorf("expected the last hashes of a and b to survive a reopen")
+	}
+	if l.Has("a", "1") {
+		t.Errorf("expected the hash a was first delivered with to be replaced")
+	}
+
+	data, _ := os.ReadFile(path)
+	if lines := len(splitLines(data)); lines != 3 {
+		t.Errorf("expected recording an unchanged hash to be a no-op, got %d lines", lines)
+	}
+}
+
+func TestOpen_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	if err := os.WriteFile(path, []byte("{\"key\":\"a\"}\n{oops\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Open(path)
+	if !apperrors.Is(err, apperrors.LedgerReadError) {
+		t.Errorf("expected %s, got %v", apperrors.LedgerReadError.Code, err)
+	}
+}
+
+func TestOpen_IgnoresRecordsWithoutHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	if err := os.WriteFile(path, []byte("{\"key\":\"5f2b\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected open error: %v", err)
+	}
+	defer l.Close()
+	if l.Has("5f2b", "") {
+		t.Errorf("expected a record without a hash to be ignored")
+	}
+}
+
+func splitLines(data []byte) []string {
+	var lines []string
+	start := 0
+	for i, b := range data {
+		if b == '\n' {
+			lines = append(lines, string(data[start:i]))
+			start = i + 1
+		}
+	}
+	return lines
+}
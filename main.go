@@ -1,30 +1,383 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"data-enricher-dispatcher/admin"
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/checkpoint"
 	"data-enricher-dispatcher/client"
 	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/deadletter"
+	"data-enricher-dispatcher/enrich"
+	"data-enricher-dispatcher/filter"
+	"data-enricher-dispatcher/ledger"
 	"data-enricher-dispatcher/logger"
+	"data-enricher-dispatcher/metrics"
+	"data-enricher-dispatcher/model"
+	"data-enricher-dispatcher/scheduler"
+	"data-enricher-dispatcher/schema"
 	"data-enricher-dispatcher/service"
+	"data-enricher-dispatcher/snapshot"
+	"data-enricher-dispatcher/tracing"
+	"data-enricher-dispatcher/transform"
 )
 
-const dotEnv = ".env"
+const (
+	dotEnv = ".env"
+
+	// shutdownTimeout bounds how long the HTTP servers of the process wait
+	// for open requests, and the trace exporter for its last spans, when it
+	// exits.
+	shutdownTimeout         = 5 * time.Second
+	serverReadHeaderTimeout = 10 * time.Second
+)
+
+// Exit codes telling an orchestrator how a run ended. Configuration and
+// startup errors exit with exitFailed through logger.Fatal.
+const (
+	exitClean   = 0
+	exitFailed  = 1
+	exitPartial = 2
+	exitAborted = 3
+)
 
 func main() {
+	os.Exit(dispatch())
+}
+
+// dispatch runs the command and returns its exit code, so the deferred
+// flushes of the sinks complete before the process exits.
+func dispatch() int {
+	replay := flag.Bool("replay", false, "re-dispatch the users recorded in DEAD_LETTER_PATH instead of fetching them")
+	resume := flag.Bool("resume", false, "continue from the checkpoint in CHECKPOINT_PATH left by an interrupted run")
+	dryRun := flag.Bool("dry-run", false, "print the payload posted for a sample user and exit without posting")
+	daemon := flag.Bool("daemon", false, "keep running and start a dispatch cycle on SCHEDULE_CRON or SCHEDULE_INTERVAL")
+	sample := flag.String("sample", "", "JSON file holding the user used by -dry-run instead of the first fetched one")
+	flag.Parse()
+
 	logger := logger.NewLogger()
+	// Checked before anything is set up, as Fatal skips the deferred closes.
+	if *replay && *resume {
+		logger.Fatal("-replay and -resume cannot be combined")
+	}
 	cfg, err := config.NewConfig(dotEnv)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
 	logger.Println("Configuration loaded successfully:", cfg)
+	ctx, stop := signalContext(context.Background())
+	defer stop()
+	if len(cfg.ExcludePostfixes) > 0 {
+		logger.Warn("EXCLUDE_POSTFIXES is deprecated, use EXCLUDE_EMAIL_SUFFIXES; its users are now excluded as the name says")
+	}
+
+	var clientOpts []client.Option
+	var payloads client.PayloadMarshaler = client.JSONMarshaler{}
+	if cfg.TransformPath != "" {
+		pipeline, err := transform.Load(cfg.TransformPath)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		payloads = pipeline
+	}
+
+	if cfg.SchemaPath != "" {
+		records, err := schema.Load(cfg.SchemaPath)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		clientOpts = append(clientOpts, client.WithRecordValidator(records))
+	}
+	// The source schema no longer describes payloads reshaped by a transform.
+	payloadSchemaPath := cfg.PayloadSchemaPath
+	if payloadSchemaPath == "" && cfg.TransformPath == "" {
+		payloadSchemaPath = cfg.SchemaPath
+	}
+	if payloadSchemaPath == "" && cfg.SchemaPath != "" {
+		logger.Warn("payloads are not validated: SCHEMA_PATH only checks source records once TRANSFORM_PATH reshapes them, set PAYLOAD_SCHEMA_PATH to check the payloads")
+	}
+	if payloadSchemaPath != "" {
+		payloadSchema, err := schema.Load(payloadSchemaPath)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		payloads = payloadSchema.Payloads(payloads)
+	}
+	clientOpts = append(clientOpts, client.WithPayloadMarshaler(payloads))
+
+	// instrumentOpts are shared by every HTTP client: the dispatch one and
+	// the one of the http_lookup enricher.
+	var instrumentOpts []client.Option
+	var opts []service.Option
+	var dispatcherMetrics *metrics.Metrics
+	if cfg.MetricsAddr != "" {
+		dispatcherMetrics = metrics.New(cfg.Environment)
+		instrumentOpts = append(instrumentOpts, client.WithMetrics(dispatcherMetrics))
+		opts = append(opts, service.WithMetrics(dispatcherMetrics))
+	}
+
+	tracerProvider, err := tracing.NewProvider(ctx, cfg, os.Stdout)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if tracerProvider != nil {
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
+				logger.Error(err)
+			}
+		}()
+		instrumentOpts = append(instrumentOpts, client.WithTracerProvider(tracerProvider))
+		opts = append(opts, service.WithTracerProvider(tracerProvider))
+	}
+
+	apiClient := client.NewAPIClientV2(cfg, append(clientOpts, instrumentOpts...)...)
+
+	userFilter, err := filter.New(cfg.FilterInclude, cfg.FilterExclude)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if !userFilter.Empty() {
+		opts = append(opts, service.WithFilter(userFilter))
+	}
+
+	var enrichers *enrich.Chain
+	if len(cfg.Enrichers) > 0 {
+		enrichers, err = enrich.FromConfig(cfg, instrumentOpts...)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		opts = append(opts, service.WithEnrichers(enrichers))
+	}
+
+	if *dryRun {
+		if err := printSamplePayload(ctx, os.Stdout, cfg, apiClient, enrichers, payloads, *sample); err != nil {
+			logger.Fatal(err)
+		}
+		return exitClean
+	}
+
+	if dispatcherMetrics != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", dispatcherMetrics.Handler())
+		defer startServer(logger, cfg.MetricsAddr, mux)()
+	}
+
+	if cfg.DeadLetterPath != "" {
+		deadLetters := deadletter.NewFileSink(cfg.DeadLetterPath)
+		defer func() {
+			if err := deadLetters.Close(); err != nil {
+				logger.Error(err)
+			}
+		}()
+		opts = append(opts, service.WithDeadLetterSink(deadLetters))
+	}
+
+	if cfg.LedgerPath != "" {
+		dispatchLedger, err := ledger.Open(cfg.LedgerPath)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer func() {
+			if err := dispatchLedger.Close(); err != nil {
+				logger.Error(err)
+			}
+		}()
+		opts = append(opts, service.WithLedger(dispatchLedger))
+	}
+
+	if cfg.CheckpointPath != "" {
+		opts = append(opts, service.WithCheckpoints(checkpoint.NewStore(cfg.CheckpointPath)))
+	}
 
-	apiClient := client.NewAPIClientV2(cfg)
+	if cfg.SnapshotPath != "" {
+		store, err := snapshot.Open(cfg.SnapshotPath)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		opts = append(opts, service.WithSnapshot(store))
+	}
+
+	dispatcher := service.NewDispatcher(apiClient, logger, cfg, opts...)
+	run := dispatcher.Start
+	switch {
+	case *replay:
+		run = dispatcher.Replay
+	case *resume:
+		run = dispatcher.Resume
+	}
+
+	var schedule *scheduler.Scheduler
+	var aborted func() bool
+	if *daemon {
+		schedule, aborted = newDaemon(cfg, logger, run)
+	}
+	if cfg.AdminAddr != "" {
+		defer startServer(logger, cfg.AdminAddr, newAdminHandler(cfg, dispatcher, apiClient, schedule))()
+	}
+	if schedule != nil {
+		return runDaemon(ctx, logger, schedule, aborted)
+	}
+	report, err := run(ctx)
+	return exitCode(logger, report, err)
+}
+
+// newAdminHandler serves the admin endpoints for dispatcher, checking that
+// apiClient reaches the source when it can, as tuned by cfg, and reporting
+// the daemon's schedule when there is one.
+func newAdminHandler(cfg *config.Config, dispatcher service.Dispatcher, apiClient client.APIClient, schedule *scheduler.Scheduler) http.Handler {
+	opts := []admin.Option{admin.WithProbeTimeout(cfg.AdminProbeTimeout), admin.WithPingTTL(cfg.AdminPingTTL)}
+	if pinger, ok := apiClient.(client.Pinger); ok {
+		opts = append(opts, admin.WithSource(pinger))
+	}
+	if schedule != nil {
+		opts = append(opts, admin.WithScheduler(schedule))
+	}
+	return admin.NewHandler(dispatcher.(service.StatusReporter), opts...)
+}
+
+// signalContext is cancelled by the first SIGINT or SIGTERM, which starts
+// the drain. The signals are released then, so a second one terminates the
+// process without waiting for it.
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// startServer serves handler on addr until the returned function is called,
+// which shuts the server down gracefully.
+func startServer(logger logger.Logger, addr string, handler http.Handler) (stop func()) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: serverReadHeaderTimeout}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error(err)
+		}
+	}
+}
 
-	ctx := context.Background()
-	dispatcher := service.NewDispatcher(apiClient, logger, cfg)
-	if err := dispatcher.Start(ctx); err != nil {
-		logger.Fatal("Failed to start dispatcher:", err)
+// exitCode logs how a run ended and maps it to the process exit code.
+func exitCode(logger logger.Logger, report *service.RunReport, err error) int {
+	switch {
+	case apperrors.Is(err, apperrors.ServiceDispatcherCanceledError):
+		logger.Warn("Dispatcher aborted:", err)
+		return exitAborted
+	case err != nil:
+		logger.Error("Dispatcher failed:", err)
+		return exitFailed
+	case report.Status != service.RunStatusSucceeded:
+		logger.Warn("Dispatcher finished with status:", report.Status)
+		return exitPartial
+	}
+	return exitClean
+}
+
+// newDaemon returns a scheduler starting run on the configured schedule,
+// along with a function reporting whether the last cycle was aborted.
+func newDaemon(cfg *config.Config, logger logger.Logger, run func(context.Context) (*service.RunReport, error)) (*scheduler.Scheduler, func() bool) {
+	schedule, err := scheduler.FromConfig(cfg.ScheduleCron, cfg.ScheduleInterval)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var lastErr error
+	job := func(ctx context.Context) (string, error) {
+		report, err := run(ctx)
+		lastErr = err
+		return report.Status, err
+	}
+	daemon := scheduler.New(schedule, job, logger,
+		scheduler.WithJitter(cfg.ScheduleJitter),
+		scheduler.WithStatusPath(cfg.ScheduleStatusPath),
+	)
+	return daemon, func() bool {
+		return apperrors.Is(lastErr, apperrors.ServiceDispatcherCanceledError)
+	}
+}
+
+// runDaemon runs the cycles of daemon until ctx is cancelled. It exits with
+// exitAborted when that interrupted a cycle, and with exitClean otherwise.
+func runDaemon(ctx context.Context, logger logger.Logger, daemon *scheduler.Scheduler, aborted func() bool) int {
+	if err := daemon.Run(ctx); err != nil {
+		logger.Error(err)
+		return exitFailed
+	}
+	if aborted() {
+		return exitAborted
+	}
+	return exitClean
+}
+
+// printSamplePayload writes the payload that would be posted for the user in
+// samplePath, or for the first user of the source when samplePath is empty,
+// after preparing it the way the dispatcher does.
+func printSamplePayload(ctx context.Context, out io.Writer, cfg *config.Config, apiClient client.APIClient, enrichers *enrich.Chain, payloads client.PayloadMarshaler, samplePath string) error {
+	var user model.User
+	if samplePath != "" {
+		data, err := os.ReadFile(samplePath)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &user); err != nil {
+			return err
+		}
+	} else {
+		users, err := apiClient.StreamUsers(ctx)
+		if err != nil {
+			return err
+		}
+		//nolint:errcheck
+		defer users.Close()
+		if !users.Next() {
+			if err := users.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("no user to sample")
+		}
+		user = users.User()
+	}
+
+	if err := service.PrepareUser(ctx, cfg, enrichers, &user); err != nil {
+		return err
+	}
+	payload, err := payloads.Marshal(user)
+	if err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, payload, "", "  "); err != nil {
+		return err
 	}
+	_, err = fmt.Fprintln(out, indented.String())
+	return err
 }
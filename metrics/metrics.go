@@ -0,0 +1,159 @@
+// Package metrics exposes the dispatcher's Prometheus metrics. Every metric
+// carries an "environment" label with the configured environment.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dispatcher"
+
+// Metrics holds the collectors updated by the dispatcher and its HTTP
+// client. A nil *Metrics is valid and records nothing, so callers do not
+// need to check whether metrics are enabled.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	usersFetched     prometheus.Counter
+	users            *prometheus.CounterVec
+	usersInFlight    prometheus.Gauge
+	runs             *prometheus.CounterVec
+	runDuration      *prometheus.HistogramVec
+	requestDuration  *prometheus.HistogramVec
+	requestRetries   *prometheus.CounterVec
+	requestsInFlight *prometheus.GaugeVec
+}
+
+// New creates the metrics of a dispatcher running in environment, along
+// with those of the Go runtime and the process.
+func New(environment string) *Metrics {
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{"environment": environment}, registry)
+	factory := promauto.With(registerer)
+
+	m := &Metrics{
+		registry: registry,
+		usersFetched: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "users_fetched_total",
+			Help:      "Users read from the source.",
+		}),
+		users: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "users_total",
+			Help:      "Users that reached an outcome, by outcome.",
+		}, []string{"outcome"}),
+		usersInFlight: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "users_in_flight",
+			Help:      "Users handed to a worker that did not reach an outcome yet.",
+		}),
+		runs: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "runs_total",
+			Help:      "Finished dispatch runs, by mode and status.",
+		}, []string{"mode", "status"}),
+		runDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "run_duration_seconds",
+			Help:      "Duration of dispatch runs, by mode.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+		}, []string{"mode"}),
+		requestDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP request attempts, by operation and status code; the code is \"error\" when no response arrived.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "code"}),
+		requestRetries: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_request_retries_total",
+			Help:      "HTTP request attempts that were retried, by operation.",
+		}, []string{"operation"}),
+		requestsInFlight: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP request attempts waiting for a response, by operation.",
+		}, []string{"operation"}),
+	}
+	registerer.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// UserFetched counts a user read from the source.
+func (m *Metrics) UserFetched() {
+	if m == nil {
+		return
+	}
+	m.usersFetched.Inc()
+}
+
+// UsersInFlight moves the gauge of users being dispatched by delta.
+func (m *Metrics) UsersInFlight(delta int) {
+	if m == nil {
+		return
+	}
+	m.usersInFlight.Add(float64(delta))
+}
+
+// UserFinished counts a user that reached outcome.
+func (m *Metrics) UserFinished(outcome string) {
+	if m == nil {
+		return
+	}
+	m.users.WithLabelValues(outcome).Inc()
+}
+
+// RunFinished counts a run of mode that ended with status after duration.
+func (m *Metrics) RunFinished(mode, status string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.runs.WithLabelValues(mode, status).Inc()
+	m.runDuration.WithLabelValues(mode).Observe(duration.Seconds())
+}
+
+// RequestStarted counts an HTTP attempt of operation as in flight.
+func (m *Metrics) RequestStarted(operation string) {
+	if m == nil {
+		return
+	}
+	m.requestsInFlight.WithLabelValues(operation).Inc()
+}
+
+// RequestFinished records an HTTP attempt of operation that ended with
+// statusCode, or without a response when statusCode is 0.
+func (m *Metrics) RequestFinished(operation string, statusCode int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requestsInFlight.WithLabelValues(operation).Dec()
+	code := "error"
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	m.requestDuration.WithLabelValues(operation, code).Observe(duration.Seconds())
+}
+
+// RequestRetried counts an HTTP attempt of operation that is retried.
+func (m *Metrics) RequestRetried(operation string) {
+	if m == nil {
+		return
+	}
+	m.requestRetries.WithLabelValues(operation).Inc()
+}
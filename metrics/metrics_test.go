@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	return recorder.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	m := New("staging")
+	m.UserFetched()
+	m.UserFetched()
+	m.UsersInFlight(2)
+	m.UsersInFlight(-1)
+	m.UserFinished("posted")
+	m.RunFinished("dispatch", "succeeded", 3*time.Second)
+	m.RequestStarted("post_user")
+	m.RequestFinished("post_user", http.StatusServiceUnavailable, 10*time.Millisecond)
+	m.RequestRetried("post_user")
+	m.RequestStarted("get_users")
+	m.RequestFinished("get_users", 0, time.Millisecond)
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`dispatcher_users_fetched_total{environment="staging"} 2`,
+		`dispatcher_users_in_flight{environment="staging"} 1`,
+		`dispatcher_users_total{environment="staging",outcome="posted"} 1`,
+		`dispatcher_runs_total{environment="staging",mode="dispatch",status="succeeded"} 1`,
+		`dispatcher_run_duration_seconds_count{environment="staging",mode="dispatch"} 1`,
+		`dispatcher_http_request_duration_seconds_count{code="503",environment="staging",operation="post_user"} 1`,
+		`dispatcher_http_request_duration_seconds_count{code="error",environment="staging",operation="get_users"} 1`,
+		`dispatcher_http_request_retries_total{environment="staging",operation="post_user"} 1`,
+		`dispatcher_http_requests_in_flight{environment="staging",operation="post_user"} 0`,
+		`go_goroutines{environment="staging"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+	m.UserFetched()
+	m.UsersInFlight(1)
+	m.UserFinished("posted")
+	m.RunFinished("dispatch", "succeeded", time.Second)
+	m.RequestStarted("post_user")
+	m.RequestFinished("post_user", http.StatusOK, time.Second)
+	m.RequestRetried("post_user")
+}
@@ -1,24 +1,112 @@
 package model
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// User mirrors the user records of the source. Fields the model does not
+// know are kept in Extra and written back unchanged, so the sink receives
+// everything the source returned.
 type User struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID       int      `json:"id,omitempty"`
+	Name     string   `json:"name"`
+	Username string   `json:"username,omitempty"`
+	Email    string   `json:"email"`
+	Phone    string   `json:"phone,omitempty"`
+	Website  string   `json:"website,omitempty"`
+	Address  *Address `json:"address,omitempty"`
+	Company  *Company `json:"company,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type Address struct {
+	Street  string `json:"street,omitempty"`
+	Suite   string `json:"suite,omitempty"`
+	City    string `json:"city,omitempty"`
+	Zipcode string `json:"zipcode,omitempty"`
+	Geo     *Geo   `json:"geo,omitempty"`
+}
+
+type Geo struct {
+	Lat string `json:"lat,omitempty"`
+	Lng string `json:"lng,omitempty"`
 }
 
+type Company struct {
+	Name        string `json:"name,omitempty"`
+	CatchPhrase string `json:"catchPhrase,omitempty"`
+	BS          string `json:"bs,omitempty"`
+}
+
+// IsValid reports whether Validate finds nothing wrong with the user.
 func (u *User) IsValid() bool {
-	if u.Name == "" || u.Email == "" {
-		return false
-	}
-	return true
+	return len(u.Validate()) == 0
 }
 
+// IsEqual reports whether both users carry the same content. Extra values
+// are compared as JSON, ignoring insignificant whitespace.
 func (u *User) IsEqual(other *User) bool {
-	if u.Name != other.Name || u.Email != other.Email {
+	if u.ID != other.ID || u.Name != other.Name || u.Username != other.Username || u.Email != other.Email ||
+		u.Phone != other.Phone || u.Website != other.Website {
 		return false
 	}
+	if !u.Address.IsEqual(other.Address) || !u.Company.IsEqual(other.Company) {
+		return false
+	}
+	if len(u.Extra) != len(other.Extra) {
+		return false
+	}
+	for key, value := range u.Extra {
+		otherValue, ok := other.Extra[key]
+		if !ok || !rawEqual(value, otherValue) {
+			return false
+		}
+	}
 	return true
 }
 
+func (a *Address) IsEqual(other *Address) bool {
+	if a == nil || other == nil {
+		return a == other
+	}
+	return a.Street == other.Street && a.Suite == other.Suite && a.City == other.City &&
+		a.Zipcode == other.Zipcode && a.Geo.IsEqual(other.Geo)
+}
+
+func (g *Geo) IsEqual(other *Geo) bool {
+	if g == nil || other == nil {
+		return g == other
+	}
+	return *g == *other
+}
+
+func (c *Company) IsEqual(other *Company) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	return *c == *other
+}
+
+// Key identifies the same user across source snapshots, even when other
+// fields change. It is the ID when the source provides one and the
+// normalized email address otherwise.
+func (u *User) Key() string {
+	if u.ID != 0 {
+		return strconv.Itoa(u.ID)
+	}
+	return strings.ToLower(strings.TrimSpace(u.Email))
+}
+
+// UserEmailHasSpecialPostfix reports whether the email of user ends with one
+// of postfix, comparing raw bytes.
+//
+// Deprecated: use EmailMatchesSuffix, which ignores case and only matches
+// whole domain labels.
 func UserEmailHasSpecialPostfix(user *User, postfix []string) bool {
 	if user == nil || user.Email == "" {
 		return false
@@ -34,3 +122,56 @@ func UserEmailHasSpecialPostfix(user *User, postfix []string) bool {
 	}
 	return false
 }
+
+// EmailDomain returns the lowercased domain of email, the part after its
+// last "@" without surrounding whitespace, or "" when it has none.
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+// EmailMatchesSuffix reports whether the domain of email matches one of
+// suffixes, ignoring case and surrounding whitespace. A suffix starting with
+// "@" matches that exact domain ("@example.com"). Any other suffix matches
+// the domain itself and its subdomains on label boundaries, so "example.com"
+// and ".com" match "a@mail.example.com" while "ample.com" does not. Emails
+// without a domain match nothing.
+func EmailMatchesSuffix(email string, suffixes []string) bool {
+	domain := EmailDomain(email)
+	if domain == "" {
+		return false
+	}
+
+	for _, suffix := range suffixes {
+		suffix = strings.ToLower(strings.TrimSpace(suffix))
+		switch {
+		case suffix == "" || suffix == "@" || suffix == ".":
+			continue
+		case strings.HasPrefix(suffix, "@"):
+			if domain == suffix[1:] {
+				return true
+			}
+		case strings.HasPrefix(suffix, "."):
+			if strings.HasSuffix(domain, suffix) {
+				return true
+			}
+		case domain == suffix || strings.HasSuffix(domain, "."+suffix):
+			return true
+		}
+	}
+	return false
+}
+
+// ContentHash returns a hex encoded SHA-256 of the user's JSON form. Users
+// with the same content share a hash, so it identifies a delivered payload.
+func (u *User) ContentHash() string {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// userJSON has the fields of User without its methods, so it can be
+// marshaled without recursing into User's own MarshalJSON.
+type userJSON User
+
+// userFields holds the JSON names of the typed User fields.
+var userFields = jsonFieldNames(reflect.TypeOf(userJSON{}))
+
+// MarshalJSON writes the typed fields followed by the Extra ones. Extra
+// entries never override a typed field.
+func (u User) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(userJSON(u))
+	if err != nil || len(u.Extra) == 0 {
+		return data, err
+	}
+
+	fields := make(map[string]json.RawMessage, len(u.Extra))
+	for key, value := range u.Extra {
+		if _, known := userFields[key]; !known {
+			fields[key] = value
+		}
+	}
+	if len(fields) == 0 {
+		return data, nil
+	}
+	extra, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both halves are JSON objects; join them into one.
+	data = append(data[:len(data)-1], ',')
+	return append(data, extra[1:]...), nil
+}
+
+// UnmarshalJSON fills the typed fields and keeps every other field in Extra.
+func (u *User) UnmarshalJSON(data []byte) error {
+	// Like encoding/json, a value of the wrong type is reported only after
+	// the remaining fields were decoded.
+	var typed userJSON
+	typeErr := json.Unmarshal(data, &typed)
+	if _, ok := typeErr.(*json.UnmarshalTypeError); typeErr != nil && !ok {
+		return typeErr
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key := range fields {
+		if _, known := userFields[key]; known {
+			delete(fields, key)
+		}
+	}
+	if len(fields) == 0 {
+		fields = nil
+	}
+
+	*u = User(typed)
+	u.Extra = fields
+	return typeErr
+}
+
+func jsonFieldNames(t reflect.Type) map[string]struct{} {
+	names := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+func rawEqual(a, b json.RawMessage) bool {
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, a) != nil || json.Compact(&compactB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
+
+// IsUserField reports whether name is the JSON name of a typed User field,
+// which Extra entries must not use.
+func IsUserField(name string) bool {
+	_, ok := userFields[name]
+	return ok
+}
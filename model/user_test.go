@@ -1,6 +1,10 @@
 package model
 
-import "testing"
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
 
 func TestUserEmailHasSpecialPostfix(t *testing.T) {
 	tests := []struct {
@@ -81,6 +85,56 @@ func TestUserEmailHasSpecialPostfix(t *testing.T) {
 	}
 }
 
+func TestEmailDomain(t *testing.T) {
+	tests := []struct {
+		email    string
+		expected string
+	}{
+		{email: "alice@Example.COM ", expected: "example.com"},
+		{email: `"a@b"@example.com`, expected: "example.com"},
+		{email: "not-an-email"},
+		{email: "alice@"},
+	}
+
+	for _, tt := range tests {
+		if domain := EmailDomain(tt.email); domain != tt.expected {
+			t.Errorf("EmailDomain(%q) = %q, want %q", tt.email, domain, tt.expected)
+		}
+	}
+}
+
+func TestEmailMatchesSuffix(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		suffixes []string
+		expected bool
+	}{
+		{name: "tld suffix", email: "Sincere@april.biz", suffixes: []string{".biz"}, expected: true},
+		{name: "case insensitive", email: "Sincere@April.BIZ", suffixes: []string{".Biz"}, expected: true},
+		{name: "surrounding whitespace", email: " Sincere@april.biz ", suffixes: []string{" .biz"}, expected: true},
+		{name: "domain", email: "alice@example.com", suffixes: []string{"example.com"}, expected: true},
+		{name: "subdomain", email: "alice@mail.example.com", suffixes: []string{"example.com"}, expected: true},
+		{name: "label boundary", email: "alice@notexample.com", suffixes: []string{"example.com"}, expected: false},
+		{name: "partial label", email: "alice@april.biz", suffixes: []string{"iz"}, expected: false},
+		{name: "exact domain", email: "alice@example.com", suffixes: []string{"@example.com"}, expected: true},
+		{name: "exact domain rejects subdomain", email: "alice@mail.example.com", suffixes: []string{"@example.com"}, expected: false},
+		{name: "local part is ignored", email: "example.com@other.org", suffixes: []string{"example.com"}, expected: false},
+		{name: "one of many", email: "alice@yahoo.com", suffixes: []string{"gmail.com", "yahoo.com"}, expected: true},
+		{name: "no domain", email: "not-an-email", suffixes: []string{".com"}, expected: false},
+		{name: "empty suffixes", email: "alice@example.com", suffixes: nil, expected: false},
+		{name: "blank suffix matches nothing", email: "alice@example.com", suffixes: []string{"", "."}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EmailMatchesSuffix(tt.email, tt.suffixes); result != tt.expected {
+				t.Errorf("EmailMatchesSuffix(%q, %q) = %v, want %v", tt.email, tt.suffixes, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestUser_IsEqual(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -124,6 +178,30 @@ func TestUser_IsEqual(t *testing.T) {
 			user2:    &User{Name: "Alice", Email: "alice@example.com"},
 			expected: false,
 		},
+		{
+			name:     "different nested address",
+			user1:    &User{Name: "Alice", Address: &Address{City: "Gwenborough", Geo: &Geo{Lat: "-37.3159"}}},
+			user2:    &User{Name: "Alice", Address: &Address{City: "Gwenborough", Geo: &Geo{Lat: "-37.3160"}}},
+			expected: false,
+		},
+		{
+			name:     "missing company",
+			user1:    &User{Name: "Alice", Company: &Company{Name: "Romaguera-Crona"}},
+			user2:    &User{Name: "Alice"},
+			expected: false,
+		},
+		{
+			name:     "extra equal as json",
+			user1:    &User{Name: "Alice", Extra: map[string]json.RawMessage{"tags": json.RawMessage(`["a", "b"]`)}},
+			user2:    &User{Name: "Alice", Extra: map[string]json.RawMessage{"tags": json.RawMessage(`["a","b"]`)}},
+			expected: true,
+		},
+		{
+			name:     "different extra",
+			user1:    &User{Name: "Alice", Extra: map[string]json.RawMessage{"tags": json.RawMessage(`["a"]`)}},
+			user2:    &User{Name: "Alice", Extra: map[string]json.RawMessage{"role": json.RawMessage(`"admin"`)}},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -162,6 +240,21 @@ func TestUser_IsValid(t *testing.T) {
 			user:     &User{Name: "", Email: ""},
 			expected: false,
 		},
+		{
+			name:     "negative id",
+			user:     &User{ID: -1, Name: "Alice", Email: "alice@example.com"},
+			expected: false,
+		},
+		{
+			name:     "extra shadowing a typed field",
+			user:     &User{Name: "Alice", Email: "alice@example.com", Extra: map[string]json.RawMessage{"email": json.RawMessage(`"bob@example.com"`)}},
+			expected: false,
+		},
+		{
+			name:     "extra with invalid json",
+			user:     &User{Name: "Alice", Email: "alice@example.com", Extra: map[string]json.RawMessage{"tags": json.RawMessage(`[`)}},
+			expected: false,
+		},
 		{
 			name:     "nil user",
 			user:     nil,
@@ -183,3 +276,65 @@ func TestUser_IsValid(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_ContentHash(t *testing.T) {
+	alice := &User{Name: "Alice", Email: "alice@example.com"}
+	same := &User{Name: "Alice", Email: "alice@example.com"}
+	changed := &User{Name: "Alice", Email: "alice@gmail.com"}
+
+	if alice.ContentHash() != same.ContentHash() {
+		t.Errorf("expected equal users to share a hash")
+	}
+	if alice.ContentHash() == changed.ContentHash() {
+		t.Errorf("expected different users to have different hashes")
+	}
+	if len(alice.ContentHash()) != 64 {
+		t.Errorf("expected a hex encoded SHA-256, got %q", alice.ContentHash())
+	}
+}
+
+func TestUser_JSONRoundTrip(t *testing.T) {
+	source := `{"id":1,"name":"Leanne Graham","username":"Bret","email":"Sincere@april.biz",` +
+		`"address":{"street":"Kulas Light","suite":"Apt. 556","city":"Gwenborough","zipcode":"92998-3874","geo":{"lat":"-37.3159","lng":"81.1496"}},` +
+		`"phone":"1-770-736-8031 x56442","website":"hildegard.org",` +
+		`"company":{"name":"Romaguera-Crona","catchPhrase":"Multi-layered client-server neural-net","bs":"harness real-time e-markets"},` +
+		`"tags":["vip"],"meta":{"source":"crm"}}`
+
+	var user User
+	if err := json.Unmarshal([]byte(source), &user); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	if user.ID != 1 || user.Username != "Bret" || user.Address.Geo.Lng != "81.1496" || user.Company.BS != "harness real-time e-markets" {
+		t.Errorf("typed fields not decoded: %+v", user)
+	}
+	if len(user.Extra) != 2 || string(user.Extra["tags"]) != `["vip"]` {
+		t.Errorf("expected unknown fields in Extra, got %v", user.Extra)
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	var want, got map[string]interface{}
+	//nolint:errcheck
+	json.Unmarshal([]byte(source), &want)
+	//nolint:errcheck
+	json.Unmarshal(data, &got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round trip changed the user:\nwant %v\ngot  %v", want, got)
+	}
+
+	var decoded User
+	if err := json.Unmarshal(data, &decoded); err != nil || !decoded.IsEqual(&user) {
+		t.Errorf("expected the marshaled user to decode to an equal user, err: %v", err)
+	}
+}
+
+func TestUser_Key(t *testing.T) {
+	if key := (&User{ID: 7, Email: "alice@example.com"}).Key(); key != "7" {
+		t.Errorf("expected the id to be the key, got %q", key)
+	}
+	if key := (&User{Email: " Alice@Example.com"}).Key(); key != "alice@example.com" {
+		t.Errorf("expected the normalized email to be the key, got %q", key)
+	}
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Validation reason codes reported in ValidationError.Code.
+const (
+	ValidationRequired       = "required"
+	ValidationInvalidEmail   = "invalid_email"
+	ValidationInvalidDomain  = "invalid_domain"
+	ValidationNegative       = "negative"
+	ValidationShadowedField  = "shadowed_field"
+	ValidationInvalidJSON    = "invalid_json"
+	ValidationUntrimmedValue = "untrimmed"
+
+	maxDomainLength = 253
+	maxLabelLength  = 63
+)
+
+// ValidationError describes why one field of a user is not acceptable.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Message == "" {
+		return e.Field + ": " + e.Code
+	}
+	return e.Field + ": " + e.Code + " (" + e.Message + ")"
+}
+
+// ValidationOptions tunes the checks of ValidateWith.
+type ValidationOptions struct {
+	// StrictDomain additionally checks the email domain the way a resolvable
+	// host name would have to look: at least two labels within the DNS
+	// length limits and a non-numeric top-level label. It never queries DNS.
+	StrictDomain bool
+}
+
+// Validate reports every field that keeps the user from being delivered,
+// or nil when there is none. The email must be a single bare RFC 5322
+// address without surrounding whitespace and with a domain that is a valid
+// internationalized domain name.
+func (u *User) Validate() []ValidationError {
+	return u.ValidateWith(ValidationOptions{})
+}
+
+// ValidateWith is Validate with the extra checks enabled in opts.
+func (u *User) ValidateWith(opts ValidationOptions) []ValidationError {
+	var errs []ValidationError
+	if u.ID < 0 {
+		errs = append(errs, ValidationError{Field: "id", Code: ValidationNegative})
+	}
+	switch {
+	case strings.TrimSpace(u.Name) == "":
+		errs = append(errs, ValidationError{Field: "name", Code: ValidationRequired})
+	case strings.TrimSpace(u.Name) != u.Name:
+		errs = append(errs, ValidationError{Field: "name", Code: ValidationUntrimmedValue})
+	}
+	if err := validateEmail(u.Email, opts); err != nil {
+		errs = append(errs, *err)
+	}
+
+	keys := make([]string, 0, len(u.Extra))
+	for key := range u.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch {
+		case IsUserField(key):
+			errs = append(errs, ValidationError{Field: key, Code: ValidationShadowedField, Message: "extra field has the name of a typed field"})
+		case !json.Valid(u.Extra[key]):
+			errs = append(errs, ValidationError{Field: key, Code: ValidationInvalidJSON})
+		}
+	}
+
+	return errs
+}
+
+func validateEmail(email string, opts ValidationOptions) *ValidationError {
+	if email == "" {
+		return &ValidationError{Field: "email", Code: ValidationRequired}
+	}
+	if strings.TrimSpace(email) != email {
+		return &ValidationError{Field: "email", Code: ValidationUntrimmedValue}
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return &ValidationError{Field: "email", Code: ValidationInvalidEmail, Message: err.Error()}
+	}
+	if address.Name != "" || address.Address != email {
+		return &ValidationError{Field: "email", Code: ValidationInvalidEmail, Message: "expected a bare address"}
+	}
+
+	domain := email[strings.LastIndex(email, "@")+1:]
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return &ValidationError{Field: "email", Code: ValidationInvalidDomain, Message: err.Error()}
+	}
+	if opts.StrictDomain {
+		if reason := checkHostName(ascii); reason != "" {
+			return &ValidationError{Field: "email", Code: ValidationInvalidDomain, Message: reason}
+		}
+	}
+	return nil
+}
+
+// checkHostName returns why domain, in its ASCII form, cannot be a host
+// name, or an empty string when it can.
+func checkHostName(domain string) string {
+	if len(domain) > maxDomainLength {
+		return fmt.Sprintf("longer than %d characters", maxDomainLength)
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "no top-level domain"
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLength {
+			return fmt.Sprintf("label %q is empty or longer than %d characters", label, maxLabelLength)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Sprintf("label %q starts or ends with a hyphen", label)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Sprintf("label %q has invalid character %q", label, c)
+			}
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "numeric top-level domain"
+	}
+	return ""
+}
+
+// NormalizeEmail trims email and lowercases it, converting an
+// internationalized domain to its ASCII (punycode) form. Values that do not
+// parse as an address are only trimmed, so validation still reports them.
+func NormalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	if address, err := mail.ParseAddress(email); err != nil || address.Name != "" || address.Address != email {
+		return email
+	}
+	domain, err := idna.Lookup.ToASCII(email[at+1:])
+	if err != nil {
+		return email
+	}
+	return strings.ToLower(email[:at]) + "@" + strings.ToLower(domain)
+}
+
+// Normalize trims the user's name and normalizes its email with
+// NormalizeEmail.
+func (u *User) Normalize() {
+	u.Name = strings.TrimSpace(u.Name)
+	u.Email = NormalizeEmail(u.Email)
+}
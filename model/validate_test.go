@@ -0,0 +1,100 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUser_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  User
+		opts  ValidationOptions
+		codes map[string]string
+	}{
+		{name: "valid", user: User{Name: "Alice", Email: "alice@example.com"}},
+		{name: "idn domain", user: User{Name: "Alice", Email: "alice@bücher.example"}},
+		{name: "punycode domain", user: User{Name: "Alice", Email: "alice@xn--bcher-kva.example"}},
+		{name: "single label domain is loose", user: User{Name: "Alice", Email: "alice@localhost"}},
+		{
+			name:  "every failing field",
+			user:  User{ID: -1, Email: "not-an-email"},
+			codes: map[string]string{"id": ValidationNegative, "name": ValidationRequired, "email": ValidationInvalidEmail},
+		},
+		{
+			name:  "untrimmed email",
+			user:  User{Name: "Leanne", Email: "Sincere@april.biz "},
+			codes: map[string]string{"email": ValidationUntrimmedValue},
+		},
+		{
+			name:  "untrimmed name",
+			user:  User{Name: " Leanne", Email: "sincere@april.biz"},
+			codes: map[string]string{"name": ValidationUntrimmedValue},
+		},
+		{
+			name:  "display name",
+			user:  User{Name: "Alice", Email: "Alice <alice@example.com>"},
+			codes: map[string]string{"email": ValidationInvalidEmail},
+		},
+		{
+			name:  "invalid idn domain",
+			user:  User{Name: "Alice", Email: "alice@exa_mple.com"},
+			codes: map[string]string{"email": ValidationInvalidDomain},
+		},
+		{
+			name:  "strict single label domain",
+			user:  User{Name: "Alice", Email: "alice@localhost"},
+			opts:  ValidationOptions{StrictDomain: true},
+			codes: map[string]string{"email": ValidationInvalidDomain},
+		},
+		{
+			name:  "strict numeric top-level domain",
+			user:  User{Name: "Alice", Email: "alice@example.123"},
+			opts:  ValidationOptions{StrictDomain: true},
+			codes: map[string]string{"email": ValidationInvalidDomain},
+		},
+		{
+			name:  "strict ip literal",
+			user:  User{Name: "Alice", Email: "alice@[127.0.0.1]"},
+			opts:  ValidationOptions{StrictDomain: true},
+			codes: map[string]string{"email": ValidationInvalidDomain},
+		},
+		{name: "strict idn domain", user: User{Name: "Alice", Email: "alice@bücher.example"}, opts: ValidationOptions{StrictDomain: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := map[string]string{}
+			for _, err := range tt.user.ValidateWith(tt.opts) {
+				codes[err.Field] = err.Code
+			}
+			if len(tt.codes) == 0 && len(codes) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(codes, tt.codes) {
+				t.Errorf("ValidateWith() codes = %v, want %v", codes, tt.codes)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email    string
+		expected string
+	}{
+		{email: " Sincere@April.BIZ ", expected: "sincere@april.biz"},
+		{email: "Alice@Bücher.Example", expected: "alice@xn--bcher-kva.example"},
+		{email: "  not-an-email ", expected: "not-an-email"},
+		{email: "Alice <alice@example.com>", expected: "Alice <alice@example.com>"},
+		{email: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			if got := NormalizeEmail(tt.email); got != tt.expected {
+				t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.expected)
+			}
+		})
+	}
+}
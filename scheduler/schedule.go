@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule decides when dispatch cycles start.
+type Schedule interface {
+	// Next returns the first start time after t.
+	Next(t time.Time) time.Time
+}
+
+type interval time.Duration
+
+// Every starts a cycle every d, counted from the end of the previous one.
+// The first cycle starts as soon as the scheduler runs.
+func Every(d time.Duration) (Schedule, error) {
+	if d <= 0 {
+		return nil, apperrors.SchedulerInvalidScheduleError.AppendMessage(fmt.Errorf("interval must be positive, got %s", d))
+	}
+	return interval(d), nil
+}
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// ParseCron parses a standard five field cron expression, such as
+// "*/15 * * * *", or a descriptor such as "@hourly". Times are local unless
+// the expression starts with "CRON_TZ=<zone>".
+func ParseCron(expr string) (Schedule, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, apperrors.SchedulerInvalidScheduleError.AppendMessage(err, expr)
+	}
+	return schedule, nil
+}
+
+// FromConfig returns the schedule set by cronExpr or every, which are
+// mutually exclusive.
+func FromConfig(cronExpr string, every time.Duration) (Schedule, error) {
+	switch {
+	case cronExpr != "" && every != 0:
+		return nil, apperrors.SchedulerInvalidScheduleError.AppendMessage(fmt.Errorf("set either a cron expression or an interval, not both"))
+	case cronExpr != "":
+		return ParseCron(cronExpr)
+	case every != 0:
+		return Every(every)
+	default:
+		return nil, apperrors.SchedulerInvalidScheduleError.AppendMessage(fmt.Errorf("no cron expression or interval set"))
+	}
+}
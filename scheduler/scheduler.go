@@ -0,0 +1,203 @@
+// Package scheduler runs dispatch cycles repeatedly on a schedule, one at a
+// time, for the daemon mode.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/logger"
+)
+
+const (
+	infoNextRun  = "next dispatch cycle at %s"
+	infoCycle    = "dispatch cycle %d finished with status %s in %s"
+	warnOverran  = "dispatch cycle %d ran past its next start time by %s; missed starts are skipped"
+	infoStopping = "scheduler stopped after %d cycles"
+)
+
+// Job runs one dispatch cycle and returns the status of its report.
+type Job func(ctx context.Context) (status string, err error)
+
+// Status describes the scheduler and its last cycle.
+type Status struct {
+	Running        bool      `json:"running"`
+	Cycles         int       `json:"cycles"`
+	LastStatus     string    `json:"last_status,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastStartedAt  time.Time `json:"last_started_at,omitzero"`
+	LastFinishedAt time.Time `json:"last_finished_at,omitzero"`
+	NextRunAt      time.Time `json:"next_run_at,omitzero"`
+}
+
+// Scheduler starts a job on every tick of a schedule. A cycle never starts
+// while the previous one is still running: starts missed in the meantime are
+// skipped and the schedule resumes from the end of the cycle.
+type Scheduler struct {
+	schedule   Schedule
+	job        Job
+	logger     logger.Logger
+	jitter     time.Duration
+	statusPath string
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Option customizes a scheduler created by New.
+type Option func(*Scheduler)
+
+// WithJitter delays every start by a random duration below jitter, so
+// several instances on the same schedule do not hit the source at once.
+func WithJitter(jitter time.Duration) Option {
+	return func(s *Scheduler) {
+		s.jitter = jitter
+	}
+}
+
+// WithStatusPath writes the status as JSON to path whenever it changes.
+func WithStatusPath(path string) Option {
+	return func(s *Scheduler) {
+		s.statusPath = path
+	}
+}
+
+func New(schedule Schedule, job Job, logger logger.Logger, opts ...Option) *Scheduler {
+	s := &Scheduler{schedule: schedule, job: job, logger: logger}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Status returns a snapshot of the scheduler's status.
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Run starts cycles until ctx is cancelled. A cycle in progress at that
+// point receives the cancellation through its context, and Run returns once
+// the job has wound down. An interval schedule starts its first cycle right
+// away, a cron schedule waits for its first tick.
+func (s *Scheduler) Run(ctx context.Context) error {
+	_, immediate := s.schedule.(interval)
+	for {
+		next := s.nextStart(time.Now(), immediate)
+		immediate = false
+		s.update(func(status *Status) { status.NextRunAt = next })
+		s.logger.Info(fmt.Sprintf(infoNextRun, next.Format(time.RFC3339)))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.update(func(status *Status) { status.NextRunAt = time.Time{} })
+			s.logger.Info(fmt.Sprintf(infoStopping, s.Status().Cycles))
+			return nil
+		case <-timer.C:
+		}
+
+		s.runCycle(ctx, next)
+		if ctx.Err() != nil {
+			s.logger.Info(fmt.Sprintf(infoStopping, s.Status().Cycles))
+			return nil
+		}
+	}
+}
+
+func (s *Scheduler) nextStart(now time.Time, immediate bool) time.Time {
+	next := now
+	if !immediate {
+		next = s.schedule.Next(now)
+	}
+	if s.jitter > 0 {
+		next = next.Add(rand.N(s.jitter))
+	}
+	return next
+}
+
+func (s *Scheduler) runCycle(ctx context.Context, scheduledAt time.Time) {
+	startedAt := time.Now()
+	var cycle int
+	s.update(func(status *Status) {
+		status.Running = true
+		status.Cycles++
+		cycle = status.Cycles
+		status.LastStartedAt = startedAt
+		status.NextRunAt = time.Time{}
+	})
+
+	runStatus, err := s.job(ctx)
+
+	finishedAt := time.Now()
+	s.update(func(status *Status) {
+		status.Running = false
+		status.LastStatus = runStatus
+		status.LastError = ""
+		if err != nil {
+			status.LastError = err.Error()
+		}
+		status.LastFinishedAt = finishedAt
+	})
+	if err != nil {
+		s.logger.Error(apperrors.SchedulerRunError.AppendMessage(err))
+	}
+	s.logger.Info(fmt.Sprintf(infoCycle, cycle, runStatus, finishedAt.Sub(startedAt)))
+	if overrun := finishedAt.Sub(s.schedule.Next(scheduledAt)); overrun > 0 {
+		s.logger.Warn(fmt.Sprintf(warnOverran, cycle, overrun))
+	}
+}
+
+// update applies change to the status and persists it.
+func (s *Scheduler) update(change func(*Status)) {
+	s.mu.Lock()
+	change(&s.status)
+	status := s.status
+	s.mu.Unlock()
+
+	if s.statusPath == "" {
+		return
+	}
+	if err := writeStatus(s.statusPath, status); err != nil {
+		s.logger.Error(err)
+	}
+}
+
+// writeStatus replaces the file at path atomically, so readers never see a
+// partial status.
+func writeStatus(path string, status Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return apperrors.SchedulerStatusWriteError.AppendMessage(err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return apperrors.SchedulerStatusWriteError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		//nolint:errcheck
+		tmp.Close()
+		return apperrors.SchedulerStatusWriteError.AppendMessage(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return apperrors.SchedulerStatusWriteError.AppendMessage(err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return apperrors.SchedulerStatusWriteError.AppendMessage(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(...interface{})   {}
+func (nopLogger) Fatal(...interface{})   {}
+func (nopLogger) Println(...interface{}) {}
+func (nopLogger) Error(...interface{})   {}
+func (nopLogger) Info(...interface{})    {}
+func (nopLogger) Warn(...interface{})    {}
+
+func TestFromConfig(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 7, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		cron     string
+		interval time.Duration
+		next     time.Time
+		wantErr  bool
+	}{
+		{name: "cron", cron: "*/15 * * * *", next: time.Date(2024, 5, 1, 10, 15, 0, 0, time.Local)},
+		{name: "descriptor", cron: "@hourly", next: time.Date(2024, 5, 1, 11, 0, 0, 0, time.Local)},
+		{name: "interval", interval: time.Hour, next: start.Add(time.Hour)},
+		{name: "both", cron: "@hourly", interval: time.Hour, wantErr: true},
+		{name: "neither", wantErr: true},
+		{name: "invalid cron", cron: "every minute", wantErr: true},
+		{name: "negative interval", interval: -time.Minute, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := FromConfig(tt.cron, tt.interval)
+			if tt.wantErr {
+				if !apperrors.Is(err, apperrors.SchedulerInvalidScheduleError) {
+					t.Fatalf("expected an invalid schedule error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if next := schedule.Next(start); !next.Equal(tt.next) {
+				t.Errorf("Next() = %s, want %s", next, tt.next)
+			}
+		})
+	}
+}
+
+func TestScheduler_RunDoesNotOverlap(t *testing.T) {
+	schedule, err := Every(time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var running, overlaps, cycles atomic.Int32
+	job := func(context.Context) (string, error) {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer running.Add(-1)
+		time.Sleep(5 * time.Millisecond)
+		if cycles.Add(1) == 3 {
+			cancel()
+		}
+		return "succeeded", nil
+	}
+
+	statusPath := filepath.Join(t.TempDir(), "status.json")
+	s := New(schedule, job, nopLogger{}, WithJitter(time.Millisecond), WithStatusPath(statusPath))
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cycles.Load() != 3 {
+		t.Errorf("expected 3 cycles, got %d", cycles.Load())
+	}
+	if overlaps.Load() != 0 {
+		t.Errorf("expected no overlapping cycles, got %d", overlaps.Load())
+	}
+
+	status := s.Status()
+	if status.Running || status.Cycles != 3 || status.LastStatus != "succeeded" || status.LastError != "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	data, err := os.ReadFile(statusPath)
+	if err != nil {
+		t.Fatalf("unexpected error reading the status file: %v", err)
+	}
+	var stored Status
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("unexpected error decoding the status file: %v", err)
+	}
+	if stored.Cycles != 3 || stored.LastStatus != "succeeded" || !stored.NextRunAt.IsZero() {
+		t.Errorf("unexpected stored status %+v", stored)
+	}
+}
+
+func TestScheduler_RunRecordsFailures(t *testing.T) {
+	schedule, err := Every(time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job := func(context.Context) (string, error) {
+		cancel()
+		return "failed", errors.New("source unavailable")
+	}
+	s := New(schedule, job, nopLogger{})
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status := s.Status()
+	if status.Cycles != 1 || status.LastStatus != "failed" || status.LastError != "source unavailable" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestScheduler_RunStopsBeforeNextCycle(t *testing.T) {
+	every, err := Every(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hourly, err := ParseCron("@hourly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		schedule       Schedule
+		expectedCycles int
+	}{
+		{name: "interval starts at once", schedule: every, expectedCycles: 1},
+		{name: "cron waits for its tick", schedule: hourly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// An hourly tick falling within the test would start a cycle.
+			if _, isInterval := tt.schedule.(interval); !isInterval && time.Until(tt.schedule.Next(time.Now())) < time.Second {
+				t.Skip("too close to the next hourly tick")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			s := New(tt.schedule, func(context.Context) (string, error) {
+				return "succeeded", nil
+			}, nopLogger{})
+			if err := s.Run(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.Status().Cycles != tt.expectedCycles {
+				t.Errorf("expected %d cycles, got %d", tt.expectedCycles, s.Status().Cycles)
+			}
+		})
+	}
+}
+
+func TestScheduler_RunCancelsCycle(t *testing.T) {
+	schedule, err := Every(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cancelled atomic.Bool
+	s := New(schedule, func(jobCtx context.Context) (string, error) {
+		cancel()
+		select {
+		case <-jobCtx.Done():
+			cancelled.Store(true)
+		case <-time.After(time.Second):
+		}
+		return "failed", jobCtx.Err()
+	}, nopLogger{})
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cancelled.Load() {
+		t.Error("expected the cycle in progress to be cancelled")
+	}
+}
@@ -0,0 +1,102 @@
+// Package schema validates source records and outgoing payloads against a
+// JSON Schema, so their shape can be enforced without changing code.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Validator checks JSON documents against a compiled schema. It is safe for
+// concurrent use.
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// Load compiles the JSON Schema stored at path. References to other files
+// are resolved relative to it. Schemas without "$schema" are read as draft
+// 2020-12, and "format" keywords such as "email" are asserted.
+func Load(path string) (*Validator, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	compiled, err := compiler.Compile(path)
+	if err != nil {
+		return nil, apperrors.SchemaLoadError.AppendMessage(err, path)
+	}
+	return &Validator{schema: compiled}, nil
+}
+
+// Validate reports every violation of the schema by data as a single
+// SchemaValidationError, listing each as "location: reason".
+func (v *Validator) Validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return apperrors.SchemaValidationError.AppendMessage(err)
+	}
+	err := v.schema.Validate(document)
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return apperrors.SchemaValidationError.AppendMessage(err)
+	}
+	return apperrors.SchemaValidationError.AppendMessage(strings.Join(violations(validationErr), "; "))
+}
+
+// violations flattens the tree of err into its leaves, which are the
+// violations a reader can act on, sorted by location.
+func violations(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		location := err.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return []string{fmt.Sprintf("%s: %s", location, err.Message)}
+	}
+	var leaves []string
+	for _, cause := range err.Causes {
+		leaves = append(leaves, violations(cause)...)
+	}
+	sort.Strings(leaves)
+	return leaves
+}
+
+// Marshaler turns a user into the payload posted to the sink. It matches
+// client.PayloadMarshaler.
+type Marshaler interface {
+	Marshal(user model.User) ([]byte, error)
+}
+
+// Payloads returns a Marshaler producing the payloads of next, failing with
+// a SchemaValidationError for those that do not match the schema.
+func (v *Validator) Payloads(next Marshaler) Marshaler {
+	return validatingMarshaler{validator: v, next: next}
+}
+
+type validatingMarshaler struct {
+	validator *Validator
+	next      Marshaler
+}
+
+func (m validatingMarshaler) Marshal(user model.User) ([]byte, error) {
+	payload, err := m.next.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validator.Validate(payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["name", "email"],
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1},
+		"email": {"type": "string", "format": "email"}
+	}
+}`
+
+func writeSchema(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidator_Validate(t *testing.T) {
+	validator, err := Load(writeSchema(t, userSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  []string
+	}{
+		{name: "valid", document: `{"id":1,"name":"Leanne","email":"sincere@april.biz","extra":true}`},
+		{name: "large id keeps precision", document: `{"id":9007199254740993,"name":"Leanne","email":"sincere@april.biz"}`},
+		{name: "missing email", document: `{"name":"Leanne"}`, wantErr: []string{"/: missing properties: 'email'"}},
+		{
+			name:     "every violation",
+			document: `{"id":"1","name":"","email":"not-an-email"}`,
+			wantErr:  []string{"/email: 'not-an-email' is not valid 'email'", "/id: expected integer, but got string", "/name: length must be >= 1, but got 0"},
+		},
+		{name: "not json", document: `{`, wantErr: []string{"unexpected EOF"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate([]byte(tt.document))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !apperrors.Is(err, apperrors.SchemaValidationError) {
+				t.Fatalf("expected a schema validation error, got %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error %q to contain %q", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); !apperrors.Is(err, apperrors.SchemaLoadError) {
+		t.Errorf("expected a load error for a missing file, got %v", err)
+	}
+	if _, err := Load(writeSchema(t, `{"type": 1}`)); !apperrors.Is(err, apperrors.SchemaLoadError) {
+		t.Errorf("expected a load error for an invalid schema, got %v", err)
+	}
+	if _, err := Load("../user_schema_example.json"); err != nil {
+		t.Errorf("unexpected error loading the example schema: %v", err)
+	}
+}
+
+type renameMarshaler struct{}
+
+func (renameMarshaler) Marshal(user model.User) ([]byte, error) {
+	return []byte(`{"full_name":"` + user.Name + `","email":"` + user.Email + `"}`), nil
+}
+
+func TestValidator_Payloads(t *testing.T) {
+	validator, err := Load(writeSchema(t, userSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	payload, err := validator.Payloads(renameMarshaler{}).Marshal(model.User{Name: "Leanne", Email: "sincere@april.biz"})
+	if !apperrors.Is(err, apperrors.SchemaValidationError) || payload != nil {
+		t.Fatalf("expected the renamed payload to be rejected, got %s, %v", payload, err)
+	}
+
+	validator, err = Load(writeSchema(t, `{"required": ["full_name"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	payload, err = validator.Payloads(renameMarshaler{}).Marshal(model.User{Name: "Leanne", Email: "sincere@april.biz"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(payload) != `{"full_name":"Leanne","email":"sincere@april.biz"}` {
+		t.Errorf("unexpected payload %s", payload)
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"data-enricher-dispatcher/apperrors"
+)
+
+const infoDeleteSkipped = "forgetting deleted user with email: %s, no delete endpoint configured"
+
+// deleteMissing deletes from the sink every snapshot user the source no
+// longer returned. It only runs after the source was read completely, as a
+// partial read would make every unread user look deleted. Users whose
+// deletion fails are kept in the snapshot so the next run tries again.
+// Without a delete endpoint they are only forgotten.
+func (d *dispatcher) deleteMissing(ctx context.Context, report *RunReport) {
+	d.setPhase(PhaseDeleting)
+	for _, user := range d.snapshot.Missing() {
+		if ctx.Err() != nil {
+			return
+		}
+		if d.cfg.DeleteUsersURL == "" {
+			d.logger.Info(fmt.Sprintf(infoDeleteSkipped, user.Email))
+			d.snapshot.Delete(user.Key())
+			d.record(report, dispatchResult{outcome: outcomeForgotten, user: user})
+			continue
+		}
+
+		if err := d.apiClient.DeleteUser(ctx, user); err != nil {
+			d.logger.Error(apperrors.ServiceDispatcherDeleteUserError.AppendMessage(err, user))
+			d.record(report, dispatchResult{outcome: outcomeDeleteFailed, user: user, code: apperrors.CodeOf(err), reason: err.Error()})
+			continue
+		}
+		d.snapshot.Delete(user.Key())
+		d.record(report, dispatchResult{outcome: outcomeDeleted, user: user})
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/checkpoint"
+	"data-enricher-dispatcher/client"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	infoNoCheckpoint  = "no checkpoint to resume from, dispatching from the start"
+	infoResuming      = "resuming after %d users from page %d, user %d"
+	warnStaleSource   = "checkpoint was taken with other source settings, dispatching from the start"
+	warnSourceChanged = "source changed since the checkpoint was taken, dispatching from the start"
+	warnNotResumable  = "the source client cannot resume, dispatching from the start"
+	infoDeleteResumed = "not looking for deleted users, a resumed run does not see the whole source"
+	noCheckpointsErr  = "CHECKPOINT_PATH is not set"
+)
+
+// progress tracks the users of a run in the order they were read and the
+// position in the source up to which every one of them was acknowledged,
+// that is reached an outcome. Users are acknowledged out of order when they
+// are dispatched concurrently, so the position only moves over contiguous
+// runs of acknowledged users. It is safe for concurrent use.
+type progress struct {
+	mu      sync.Mutex
+	pending map[int]pendingUser
+	next    int
+	current checkpoint.Checkpoint
+	page    checkpoint.Fingerprint
+	savedAt time.Time
+}
+
+type pendingUser struct {
+	position client.Position
+	user     model.User
+	acked    bool
+}
+
+// newProgress tracks the run over users when checkpoints are enabled and
+// users knows its position in the source. It starts at base, with page the
+// fingerprint of the users acknowledged on the page of base.
+func (d *dispatcher) newProgress(users client.UserIterator, base checkpoint.Checkpoint, page checkpoint.Fingerprint) *progress {
+	if d.checkpoints == nil {
+		return nil
+	}
+	if _, ok := users.(client.Positioner); !ok {
+		return nil
+	}
+	return &progress{pending: make(map[int]pendingUser), current: base, page: page, savedAt: time.Now()}
+}
+
+// read records that the user read in position seq was found at position.
+func (p *progress) read(seq int, position client.Position, user model.User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending[seq] = pendingUser{position: position, user: user}
+}
+
+// ack records that the user read in position seq reached an outcome and
+// returns the checkpoint the run has advanced to.
+func (p *progress) ack(seq int) checkpoint.Checkpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pending, ok := p.pending[seq]; ok {
+		pending.acked = true
+		p.pending[seq] = pending
+	}
+	for {
+		pending, ok := p.pending[p.next]
+		if !ok || !pending.acked {
+			break
+		}
+		delete(p.pending, p.next)
+		p.next++
+
+		if pending.position.PageURL != p.current.PageURL || pending.position.Page != p.current.Page {
+			p.page = checkpoint.Fingerprint{}
+		}
+		p.page.Add(pending.user)
+		p.current.PageURL = pending.position.PageURL
+		p.current.Page = pending.position.Page
+		p.current.Index = pending.position.Index + 1
+		p.current.Fingerprint = p.page.String()
+		p.current.Acknowledged++
+	}
+	return p.current
+}
+
+// checkpointTracker records the progress of a run that is checkpointed.
+type checkpointTracker struct {
+	d        *dispatcher
+	progress *progress
+}
+
+func (t checkpointTracker) read(seq int, users client.UserIterator) {
+	t.progress.read(seq, users.(client.Positioner).Position(), users.User())
+}
+
+func (t checkpointTracker) acknowledge(result dispatchResult) {
+	t.d.acknowledge(t.progress, result.seq)
+}
+
+// acknowledge records the outcome of the user read in position seq and saves
+// the checkpoint when the last save is older than the checkpoint interval.
+func (d *dispatcher) acknowledge(p *progress, seq int) {
+	current := p.ack(seq)
+	if time.Since(p.savedAt) < d.cfg.CheckpointInterval {
+		return
+	}
+	p.savedAt = time.Now()
+	if err := d.checkpoints.Save(current); err != nil {
+		d.logger.Error(apperrors.ServiceDispatcherCheckpointError.AppendMessage(err))
+	}
+}
+
+// finishProgress clears the checkpoint of a run that completed and saves the
+// final one of a run that stopped early.
+func (d *dispatcher) finishProgress(p *progress, err error) {
+	if err == nil {
+		if clearErr := d.checkpoints.Clear(); clearErr != nil {
+			d.logger.Error(apperrors.ServiceDispatcherCheckpointError.AppendMessage(clearErr))
+		}
+		return
+	}
+
+	p.mu.Lock()
+	current := p.current
+	p.mu.Unlock()
+	if saveErr := d.checkpoints.Save(current); saveErr != nil {
+		d.logger.Error(apperrors.ServiceDispatcherCheckpointError.AppendMessage(saveErr))
+	}
+}
+
+// resume dispatches from the stored checkpoint. The users the checkpoint says
+// were acknowledged on its page are read again and compared with its
+// fingerprint first; when the source changed, or the checkpoint cannot be
+// used, the run starts from the beginning instead.
+func (d *dispatcher) resume(ctx context.Context, report *RunReport) error {
+	if d.checkpoints == nil {
+		return apperrors.ServiceDispatcherResumeError.AppendMessage(noCheckpointsErr)
+	}
+	stored, err := d.checkpoints.Load()
+	if err != nil {
+		return apperrors.ServiceDispatcherResumeError.AppendMessage(err)
+	}
+	resumer, resumable := d.apiClient.(client.Resumer)
+	switch {
+	case stored == nil || stored.Acknowledged == 0:
+		d.logger.Info(infoNoCheckpoint)
+		return d.run(ctx, report)
+	case stored.Source != sourceFingerprint(d.cfg):
+		d.logger.Warn(warnStaleSource)
+		return d.run(ctx, report)
+	case !resumable:
+		d.logger.Warn(warnNotResumable)
+		return d.run(ctx, report)
+	}
+
+	users, err := resumer.StreamUsersFrom(ctx, client.Position{PageURL: stored.PageURL, Page: stored.Page})
+	if err != nil {
+		return apperrors.ServiceDispatcherGetUsersError.AppendMessage(err)
+	}
+	var page checkpoint.Fingerprint
+	for i := 0; i < stored.Index && users.Next(); i++ {
+		page.Add(users.User())
+	}
+	if users.Err() != nil || page.String() != stored.Fingerprint {
+		//nolint:errcheck
+		users.Close()
+		d.logger.Warn(warnSourceChanged)
+		return d.run(ctx, report)
+	}
+
+	d.logger.Info(fmt.Sprintf(infoResuming, stored.Acknowledged, stored.Page, stored.Index))
+	report.ResumedFrom = stored.Acknowledged
+	return d.runFrom(ctx, users, report, d.newProgress(users, *stored, page))
+}
+
+// sourceFingerprint identifies the settings that decide which users the
+// source returns and in what pages, so a checkpoint is not applied to
+// another source.
+func sourceFingerprint(cfg *config.Config) string {
+	settings := []string{
+		cfg.GetUsersURL,
+		strings.ToLower(cfg.GetUsersPagination),
+		cfg.GetUsersPageParam,
+		cfg.GetUsersLimitParam,
+		strconv.Itoa(cfg.GetUsersPageSize),
+		cfg.GetUsersCursorParam,
+		cfg.GetUsersCursorHeader,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(settings, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-enricher-dispatcher/checkpoint"
+	"data-enricher-dispatcher/client"
+	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/model"
+	"data-enricher-dispatcher/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedIterator walks pages of users from a starting page and knows the
+// position of each of them.
+type pagedIterator struct {
+	pages [][]model.User
+	page  int
+	index int
+}
+
+func newPagedIterator(pages [][]model.User, fromPage int) *pagedIterator {
+	return &pagedIterator{pages: pages, page: fromPage - 1, index: -1}
+}
+
+func (it *pagedIterator) Next() bool {
+	it.index++
+	for it.page < len(it.pages) && it.index >= len(it.pages[it.page]) {
+		it.page++
+		it.index = 0
+	}
+	return it.page < len(it.pages)
+}
+
+func (it *pagedIterator) User() model.User { return it.pages[it.page][it.index] }
+func (it *pagedIterator) Rejected() error  { return nil }
+func (it *pagedIterator) Err() error       { return nil }
+func (it *pagedIterator) Close() error     { return nil }
+
+func (it *pagedIterator) Position() client.Position {
+	return client.Position{PageURL: fmt.Sprintf("page-%d", it.page+1), Page: it.page + 1, Index: it.index}
+}
+
+func checkpointUsers() ([]model.User, [][]model.User) {
+	users := make([]model.User, 5)
+	for i := range users {
+		users[i] = model.User{ID: i + 1, Name: fmt.Sprintf("User %d", i+1), Email: fmt.Sprintf("user%d@test.com", i+1)}
+	}
+	return users, [][]model.User{users[0:2], users[2:4], users[4:5]}
+}
+
+// interruptedRun dispatches pages until the third user is posted, which
+// cancels the run, and returns the checkpoint store it left.
+func interruptedRun(t *testing.T, cfg *config.Config, pages [][]model.User, users []model.User) *checkpoint.Store {
+	t.Helper()
+	store := checkpoint.NewStore(cfg.CheckpointPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsers", mock.Anything).Return(newPagedIterator(pages, 1), nil)
+	mockClient.On("PostUser", mock.Anything, users[0]).Return(nil).Once()
+	mockClient.On("PostUser", mock.Anything, users[1]).Return(nil).Once()
+	mockClient.On("PostUser", mock.Anything, users[2]).Run(func(mock.Arguments) { cancel() }).Return(nil).Once()
+	mockLogger.On("Info", mock.Anything)
+	mockLogger.On("Warn", mock.Anything)
+
+	_, err := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithCheckpoints(store)).Start(ctx)
+	require.Error(t, err)
+	mockClient.AssertNotCalled(t, "PostUser", mock.Anything, users[3])
+	return store
+}
+
+func TestDispatcher_StartSavesCheckpoint(t *testing.T) {
+	users, pages := checkpointUsers()
+	cfg := &config.Config{CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.json"), ShutdownGraceTimeout: time.Second}
+
+	store := interruptedRun(t, cfg, pages, users)
+
+	stored, err := store.Load()
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	var fingerprint checkpoint.Fingerprint
+	fingerprint.Add(users[2])
+	assert.Equal(t, "page-2", stored.PageURL)
+	assert.Equal(t, 2, stored.Page)
+	assert.Equal(t, 1, stored.Index)
+	assert.Equal(t, 3, stored.Acknowledged)
+	assert.Equal(t, fingerprint.String(), stored.Fingerprint)
+}
+
+func TestDispatcher_Resume(t *testing.T) {
+	users, pages := checkpointUsers()
+	cfg := &config.Config{CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.json"), ShutdownGraceTimeout: time.Second}
+	store := interruptedRun(t, cfg, pages, users)
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsersFrom", mock.Anything, client.Position{PageURL: "page-2", Page: 2}).Return(newPagedIterator(pages, 2), nil)
+	mockClient.On("PostUser", mock.Anything, users[3]).Return(nil).Once()
+	mockClient.On("PostUser", mock.Anything, users[4]).Return(nil).Once()
+	mockLogger.On("Info", mock.Anything)
+
+	report, err := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithCheckpoints(store)).Resume(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, service.RunModeResume, report.Mode)
+	assert.Equal(t, service.RunStatusSucceeded, report.Status)
+	assert.Equal(t, 3, report.ResumedFrom)
+	assert.Equal(t, 2, report.Fetched)
+	assert.Equal(t, 2, report.Posted)
+	mockClient.AssertExpectations(t)
+
+	stored, err := store.Load()
+	require.NoError(t, err)
+	assert.Nil(t, stored, "a completed run clears the checkpoint")
+}
+
+func TestDispatcher_ResumeFromStart(t *testing.T) {
+	users, pages := checkpointUsers()
+	changed := [][]model.User{pages[0], {{ID: 3, Name: "Renamed", Email: "user3@test.com"}, users[3]}, pages[2]}
+
+	tests := []struct {
+		name        string
+		interrupted bool
+		cfg         func(cfg *config.Config)
+		resumeFrom  [][]model.User
+	}{
+		{name: "no checkpoint"},
+		{name: "source changed", interrupted: true, resumeFrom: changed},
+		{name: "source settings changed", interrupted: true, cfg: func(cfg *config.Config) { cfg.GetUsersPageSize = 50 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.json"), ShutdownGraceTimeout: time.Second}
+			store := checkpoint.NewStore(cfg.CheckpointPath)
+			if tt.interrupted {
+				store = interruptedRun(t, cfg, pages, users)
+			}
+			if tt.cfg != nil {
+				tt.cfg(cfg)
+			}
+
+			mockClient := new(MockAPIClient)
+			mockLogger := new(MockLogger)
+			if tt.resumeFrom != nil {
+				mockClient.On("StreamUsersFrom", mock.Anything, mock.Anything).Return(newPagedIterator(tt.resumeFrom, 2), nil)
+			}
+			mockClient.On("StreamUsers", mock.Anything).Return(newPagedIterator(pages, 1), nil)
+			for _, user := range users {
+				mockClient.On("PostUser", mock.Anything, user).Return(nil).Once()
+			}
+			mockLogger.On("Info", mock.Anything)
+			mockLogger.On("Warn", mock.Anything)
+
+			report, err := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithCheckpoints(store)).Resume(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 0, report.ResumedFrom)
+			assert.Equal(t, len(users), report.Posted)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
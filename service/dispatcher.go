@@ -2,65 +2,680 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"sync"
 	"time"
 
 	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/checkpoint"
 	"data-enricher-dispatcher/client"
 	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/deadletter"
+	"data-enricher-dispatcher/enrich"
+	"data-enricher-dispatcher/filter"
+	"data-enricher-dispatcher/ledger"
 	"data-enricher-dispatcher/logger"
+	"data-enricher-dispatcher/metrics"
 	"data-enricher-dispatcher/model"
+	"data-enricher-dispatcher/snapshot"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 const (
-	defaultTimeout = 10 * time.Second
-	infoSkipping   = "skipping user with email: %s due to special postfix exclusion"
+	defaultConcurrency = 1
+	defaultBatchSize   = 1
+	infoNotIncluded    = "skipping user with email: %s not matching the included email suffixes"
+	infoExcluded       = "skipping user with email: %s matching an excluded email suffix"
+	debugDuplicate     = "skipping user with email: %s already delivered with the same content"
+	debugUnchanged     = "skipping user with email: %s unchanged since the last snapshot"
+	infoEnrichSkipped  = "skipping user with email: %s: %v"
+	infoFiltered       = "skipping user with email: %s that %s"
+	infoDraining       = "shutting down: waiting up to %s for posts in flight"
+	warnDrainTimeout   = "shutting down: posts still in flight after %s are cancelled"
+	infoSummary        = "dispatch %s: fetched=%d posted=%d skipped=%d duplicates=%d invalid=%d failed=%d created=%d updated=%d unchanged=%d deleted=%d forgotten=%d duration=%s rate_limit_wait=%s"
 )
 
 type Dispatcher interface {
-	Start(ctx context.Context) error
+	// Start runs one dispatch cycle. The returned report is never nil, even
+	// when the run fails, so callers can always inspect how far it got.
+	Start(ctx context.Context) (*RunReport, error)
+	// Replay re-dispatches every user recorded in the dead-letter file.
+	Replay(ctx context.Context) (*RunReport, error)
+	// Resume runs a dispatch cycle from the checkpoint left by an
+	// interrupted one, or from the start when there is none.
+	Resume(ctx context.Context) (*RunReport, error)
 }
 
 type dispatcher struct {
-	apiClient client.APIClient
-	logger    logger.Logger
-	cfg       *config.Config
+	apiClient   client.APIClient
+	logger      logger.Logger
+	cfg         *config.Config
+	deadLetters deadletter.Sink
+	ledger      ledger.Ledger
+	snapshot    *snapshot.Store
+	enrichers   *enrich.Chain
+	filter      *filter.Filter
+	checkpoints *checkpoint.Store
+	metrics     *metrics.Metrics
+	tracer      trace.Tracer
+	state       runState
+}
+
+// Option customizes a dispatcher created by NewDispatcher.
+type Option func(*dispatcher)
+
+// WithDeadLetterSink records users that could not be posted in sink.
+func WithDeadLetterSink(sink deadletter.Sink) Option {
+	return func(d *dispatcher) {
+		d.deadLetters = sink
+	}
+}
+
+// WithLedger skips users whose unchanged content was already delivered
+// according to l and records every new delivery in it.
+func WithLedger(l ledger.Ledger) Option {
+	return func(d *dispatcher) {
+		d.ledger = l
+	}
+}
+
+// WithSnapshot enables change-data-capture mode: users are diffed against
+// store, only created and updated ones are posted, and users missing from the
+// source are deleted from the sink. store is saved at the end of every run.
+func WithSnapshot(store *snapshot.Store) Option {
+	return func(d *dispatcher) {
+		d.snapshot = store
+	}
 }
 
-func NewDispatcher(apiClient client.APIClient, logger logger.Logger, cfg *config.Config) Dispatcher {
-	return &dispatcher{
+// WithFilter skips the users f does not allow.
+func WithFilter(f *filter.Filter) Option {
+	return func(d *dispatcher) {
+		d.filter = f
+	}
+}
+
+// WithCheckpoints saves the position up to which a run has handled the
+// source to store, every CHECKPOINT_INTERVAL and when the run stops early, so
+// Resume can pick up from there.
+func WithCheckpoints(store *checkpoint.Store) Option {
+	return func(d *dispatcher) {
+		d.checkpoints = store
+	}
+}
+
+// WithMetrics records the users and runs handled by the dispatcher in m.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(d *dispatcher) {
+		d.metrics = m
+	}
+}
+
+// WithTracerProvider records a span in tp for every run and every user,
+// with child spans for the filter, enrich and post stages.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(d *dispatcher) {
+		d.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithEnrichers runs chain on every valid user before it is posted.
+func WithEnrichers(chain *enrich.Chain) Option {
+	return func(d *dispatcher) {
+		d.enrichers = chain
+	}
+}
+
+func NewDispatcher(apiClient client.APIClient, logger logger.Logger, cfg *config.Config, opts ...Option) Dispatcher {
+	d := &dispatcher{
 		apiClient: apiClient,
 		logger:    logger,
 		cfg:       cfg,
+		tracer:    noop.NewTracerProvider().Tracer(tracerName),
+		state:     runState{status: Status{Phase: PhaseIdle}},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+func (d *dispatcher) Start(ctx context.Context) (*RunReport, error) {
+	return d.runMode(ctx, RunModeDispatch, d.run)
+}
+
+func (d *dispatcher) Replay(ctx context.Context) (*RunReport, error) {
+	return d.runMode(ctx, RunModeReplay, d.replay)
+}
+
+func (d *dispatcher) Resume(ctx context.Context) (*RunReport, error) {
+	return d.runMode(ctx, RunModeResume, d.resume)
+}
+
+// runMode runs one dispatch cycle of mode within a span of its own.
+func (d *dispatcher) runMode(ctx context.Context, mode string, run func(context.Context, *RunReport) error) (*RunReport, error) {
+	ctx, span := d.tracer.Start(ctx, "dispatch "+mode, trace.WithAttributes(modeAttribute.String(mode)))
+	report, stats := newRunReport(mode, time.Now()), d.clientStats()
+	d.beginRun(mode, report.StartedAt)
+	err := run(ctx, report)
+	report = d.finish(report, stats, err)
+	d.endRun(report)
+	endRunSpan(span, report)
+	return report, err
+}
+
+// finish completes report, attributing to it the client stats gathered
+// since startStats were taken, and logs and stores it.
+func (d *dispatcher) finish(report *RunReport, startStats client.Stats, err error) *RunReport {
+	report.RateLimitWait = Duration(d.clientStats().RateLimitWait - startStats.RateLimitWait)
+	report.finish(time.Now(), err)
+	d.metrics.RunFinished(report.Mode, report.Status, time.Duration(report.Duration))
+
+	if d.snapshot != nil {
+		if saveErr := d.snapshot.Save(); saveErr != nil {
+			d.logger.Error(apperrors.ServiceDispatcherSnapshotError.AppendMessage(saveErr))
+		}
+	}
+
+	d.logger.Info(fmt.Sprintf(infoSummary, report.Status, report.Fetched, report.Posted, report.Skipped, report.Duplicates, report.Invalid, report.Failed,
+		report.Created, report.Updated, report.Unchanged, report.Deleted, report.Forgotten,
+		time.Duration(report.Duration), time.Duration(report.RateLimitWait)))
+	if d.cfg.ReportPath != "" {
+		if writeErr := report.WriteFile(d.cfg.ReportPath); writeErr != nil {
+			d.logger.Error(apperrors.ServiceDispatcherWriteReportError.AppendMessage(writeErr, d.cfg.ReportPath))
+		}
 	}
+
+	return report
 }
 
-func (d *dispatcher) Start(ctx context.Context) error {
-	userCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
-	defer cancel()
+// record adds result to report and counts it in the metrics and the status.
+func (d *dispatcher) record(report *RunReport, result dispatchResult) {
+	report.add(result)
+	d.metrics.UserFinished(result.outcome.String())
+	d.state.update(func(status *Status) {
+		status.Finished++
+	})
+}
 
-	users, err := d.apiClient.GetUsers(userCtx)
+func (d *dispatcher) clientStats() client.Stats {
+	if reporter, ok := d.apiClient.(client.StatsReporter); ok {
+		return reporter.Stats()
+	}
+	return client.Stats{}
+}
+
+func (d *dispatcher) run(ctx context.Context, report *RunReport) error {
+	users, err := d.apiClient.StreamUsers(ctx)
 	if err != nil {
 		return apperrors.ServiceDispatcherGetUsersError.AppendMessage(err)
 	}
-	for _, user := range users {
-		if !model.UserEmailHasSpecialPostfix(&user, d.cfg.ExcludePostfixes) {
-			err := fmt.Errorf(infoSkipping, user.Email)
-			d.logger.Info(err.Error())
-			continue
+
+	return d.runFrom(ctx, users, report, d.newProgress(users, checkpoint.Checkpoint{Source: sourceFingerprint(d.cfg)}, checkpoint.Fingerprint{}))
+}
+
+// runFrom dispatches the users of the iterator, which it closes, recording
+// the progress of the run when there is one. Deleted users are only looked
+// for when the run read the source from its start.
+func (d *dispatcher) runFrom(ctx context.Context, users client.UserIterator, report *RunReport, progress *progress) error {
+	defer func() {
+		if closeErr := users.Close(); closeErr != nil {
+			d.logger.Warn(closeErr)
+		}
+	}()
+
+	if d.snapshot != nil {
+		d.snapshot.Begin()
+	}
+	var tracker runTracker
+	if progress != nil {
+		tracker = checkpointTracker{d: d, progress: progress}
+	}
+	err := d.dispatch(ctx, users, report, tracker)
+	if progress != nil {
+		d.finishProgress(progress, err)
+	}
+	if err != nil {
+		return err
+	}
+	if d.snapshot != nil {
+		if report.ResumedFrom > 0 {
+			d.logger.Info(infoDeleteResumed)
+		} else {
+			d.deleteMissing(ctx, report)
+		}
+	}
+
+	return nil
+}
+
+// runTracker follows the users of a run from the moment they are read until
+// they reach an outcome. Users are identified by the order they were read in,
+// found in the seq of their result.
+type runTracker interface {
+	read(seq int, users client.UserIterator)
+	acknowledge(result dispatchResult)
+}
+
+// dispatch posts every user of the iterator and reports why it stopped early,
+// if it did. tracker, when not nil, is told about every user.
+func (d *dispatcher) dispatch(ctx context.Context, users client.UserIterator, report *RunReport, tracker runTracker) error {
+	drained := d.dispatchUsers(ctx, users, report, tracker)
+
+	if ctx.Err() != nil && (!drained || report.total() < report.Fetched) {
+		return apperrors.ServiceDispatcherCanceledError.AppendMessage(ctx.Err())
+	}
+	if err := users.Err(); err != nil {
+		return apperrors.ServiceDispatcherGetUsersError.AppendMessage(err)
+	}
+
+	return nil
+}
+
+// dispatchUsers fans users out to a bounded pool of workers as they are read
+// from the iterator and blocks until every worker has drained, recording each
+// result in report. Users are handed out in batches of the configured size.
+// It returns whether the iterator was read to the end; when ctx is cancelled
+// the remaining users are left unread, while batches already being posted
+// get the shutdown grace timeout to complete.
+func (d *dispatcher) dispatchUsers(ctx context.Context, users client.UserIterator, report *RunReport, tracker runTracker) (drained bool) {
+	inFlightCtx, cancelInFlight := d.drainContext(ctx)
+	defer cancelInFlight()
+
+	workers := d.cfg.DispatchConcurrency
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+	batchSize := d.cfg.PostBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	jobs := make(chan dispatchJob)
+	results := make(chan dispatchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				d.usersInFlight(len(job.users))
+				for i, result := range d.dispatchBatch(inFlightCtx, job.users) {
+					result.seq = job.seqs[i]
+					results <- result
+				}
+				d.usersInFlight(-len(job.users))
+			}
+		}()
+	}
+
+	var fetched int
+	go func() {
+		defer close(jobs)
+		job := newDispatchJob(batchSize)
+		for seq := 0; ; seq++ {
+			if ctx.Err() != nil {
+				return
+			}
+			more := users.Next()
+			if more {
+				d.userFetched()
+			}
+			if more && tracker != nil {
+				tracker.read(seq, users)
+			}
+			if more && users.Rejected() != nil {
+				fetched++
+				result := d.rejectedResult(users.User(), users.Rejected())
+				result.seq = seq
+				results <- result
+				continue
+			}
+			if more {
+				job.users = append(job.users, users.User())
+				job.seqs = append(job.seqs, seq)
+				if len(job.users) < batchSize {
+					continue
+				}
+			}
+			if len(job.users) > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case jobs <- job:
+					fetched += len(job.users)
+					job = newDispatchJob(batchSize)
+				}
+			}
+			if !more {
+				drained = true
+				return
+			}
 		}
-		if !user.IsValid() {
-			d.logger.Println(apperrors.ServiceDispatcherInvalidUserError.AppendMessage(user))
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		d.record(report, result)
+		if tracker != nil {
+			tracker.acknowledge(result)
+		}
+	}
+	report.Fetched = fetched
+
+	return drained
+}
+
+// drainContext returns a context for the calls in flight that outlives ctx
+// by the shutdown grace timeout, so posts under way when ctx is cancelled can
+// complete while no new ones start.
+func (d *dispatcher) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	inFlightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-inFlightCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		grace := d.cfg.ShutdownGraceTimeout
+		if grace <= 0 {
+			cancel()
+			return
+		}
+		d.logger.Info(fmt.Sprintf(infoDraining, grace))
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-inFlightCtx.Done():
+		case <-timer.C:
+			d.logger.Warn(fmt.Sprintf(warnDrainTimeout, grace))
+			cancel()
+		}
+	}()
+	return inFlightCtx, cancel
+}
+
+// dispatchJob is a batch of users handed to a worker, along with the order
+// in which each of them was read from the source.
+type dispatchJob struct {
+	users []model.User
+	seqs  []int
+}
+
+func newDispatchJob(batchSize int) dispatchJob {
+	return dispatchJob{users: make([]model.User, 0, batchSize), seqs: make([]int, 0, batchSize)}
+}
+
+// dispatchBatch screens every user of batch and posts the ones that pass,
+// one by one or through the bulk endpoint when batching is enabled. The
+// results are in the order of batch.
+func (d *dispatcher) dispatchBatch(ctx context.Context, batch []model.User) []dispatchResult {
+	results := make([]dispatchResult, len(batch))
+	spans := make([]trace.Span, len(batch))
+	postable := make([]model.User, 0, len(batch))
+	postableAt := make([]int, 0, len(batch))
+	postableCtxs := make([]context.Context, 0, len(batch))
+	changes := make([]snapshot.Change, 0, len(batch))
+	for i, user := range batch {
+		userCtx, span := d.startUserSpan(ctx, user)
+		spans[i] = span
+		result, ok := d.screenUser(userCtx, user)
+		if !ok {
+			results[i] = result
 			continue
 		}
+		postable = append(postable, result.user)
+		postableAt = append(postableAt, i)
+		postableCtxs = append(postableCtxs, userCtx)
+		changes = append(changes, result.change)
+	}
+
+	var posted []dispatchResult
+	switch {
+	case len(postable) == 0:
+	case d.cfg.PostBatchSize <= 1:
+		for i, user := range postable {
+			posted = append(posted, d.postUser(postableCtxs[i], user))
+		}
+	default:
+		posted = d.postUsers(ctx, postable, postableCtxs)
+	}
+	for i, result := range posted {
+		result.change = changes[i]
+		results[postableAt[i]] = result
+	}
+	for i, result := range results {
+		endUserSpan(spans[i], result)
+	}
+
+	return results
+}
+
+// PrepareUser applies to user the normalization and enrichment a dispatcher
+// configured with cfg and enrichers applies before posting it, without
+// filtering or validating it. enrichers may be nil.
+func PrepareUser(ctx context.Context, cfg *config.Config, enrichers *enrich.Chain, user *model.User) error {
+	normalizeUser(cfg, user)
+	if enrichers == nil {
+		return nil
+	}
+	_, err := runEnrichers(ctx, enrichers, user)
+	return err
+}
+
+func normalizeUser(cfg *config.Config, user *model.User) {
+	if cfg.EmailNormalize {
+		user.Normalize()
+	}
+}
+
+func runEnrichers(ctx context.Context, enrichers *enrich.Chain, user *model.User) ([]error, error) {
+	// The source's Extra map is copied so enrichers never write through to it.
+	user.Extra = maps.Clone(user.Extra)
+	return enrichers.Enrich(ctx, user)
+}
+
+// screenUser applies the normalization, filters, validation and enrichment
+// that decide whether user is posted at all. It returns false together with
+// the result for users that are not, and the enriched user otherwise.
+func (d *dispatcher) screenUser(ctx context.Context, user model.User) (dispatchResult, bool) {
+	if result, ok := d.filterUser(ctx, &user); !ok {
+		return result, false
+	}
+	if d.enrichers != nil {
+		if result, ok := d.enrichUser(ctx, &user); !ok {
+			return result, false
+		}
+	}
+	var change snapshot.Change
+	if d.snapshot != nil {
+		if change = d.snapshot.Classify(user); change == snapshot.Unchanged {
+			d.logger.Debug(fmt.Sprintf(debugUnchanged, user.Email))
+			return dispatchResult{outcome: outcomeUnchanged, user: user}, false
+		}
+	}
+	if d.ledger != nil && d.ledger.Has(user.Key(), user.ContentHash()) {
+		d.logger.Debug(fmt.Sprintf(debugDuplicate, user.Email))
+		return dispatchResult{outcome: outcomeDuplicate, user: user}, false
+	}
+
+	return dispatchResult{user: user, change: change}, true
+}
 
-		postUserCtx, postCancel := context.WithTimeout(ctx, defaultTimeout)
-		defer postCancel()
-		err := d.apiClient.PostUser(postUserCtx, user)
+// filterUser normalizes user and reports whether it passes the email
+// suffixes, the filter and validation, with the result for when it does not.
+func (d *dispatcher) filterUser(ctx context.Context, user *model.User) (result dispatchResult, ok bool) {
+	_, span := d.tracer.Start(ctx, "filter")
+	defer func() { endStageSpan(span, result, ok) }()
+
+	normalizeUser(d.cfg, user)
+	if d.snapshot != nil {
+		d.snapshot.Observe(*user)
+	}
+	if len(d.cfg.IncludeEmailSuffixes) > 0 && !model.EmailMatchesSuffix(user.Email, d.cfg.IncludeEmailSuffixes) {
+		d.logger.Info(fmt.Sprintf(infoNotIncluded, user.Email))
+		return dispatchResult{outcome: outcomeSkipped, user: *user}, false
+	}
+	if model.EmailMatchesSuffix(user.Email, d.cfg.ExcludeEmailSuffixes) {
+		d.logger.Info(fmt.Sprintf(infoExcluded, user.Email))
+		return dispatchResult{outcome: outcomeSkipped, user: *user}, false
+	}
+	if d.filter != nil {
+		if allowed, reason := d.filter.Allows(user); !allowed {
+			d.logger.Info(fmt.Sprintf(infoFiltered, user.Email, reason))
+			return dispatchResult{outcome: outcomeSkipped, user: *user}, false
+		}
+	}
+	return d.validateUser(*user)
+}
+
+// enrichUser runs the enricher chain on user and reports whether it may
+// still be posted, with the result for when it may not.
+func (d *dispatcher) enrichUser(ctx context.Context, user *model.User) (result dispatchResult, ok bool) {
+	ctx, span := d.tracer.Start(ctx, "enrich")
+	defer func() { endStageSpan(span, result, ok) }()
+
+	warnings, err := runEnrichers(ctx, d.enrichers, user)
+	for _, warning := range warnings {
+		d.logger.Warn(apperrors.ServiceDispatcherEnrichError.AppendMessage(warning, user.Email))
+	}
+	switch {
+	case errors.Is(err, enrich.ErrSkipped):
+		d.logger.Info(fmt.Sprintf(infoEnrichSkipped, user.Email, err))
+		return dispatchResult{outcome: outcomeSkipped, user: *user}, false
+	case err != nil:
+		d.logger.Error(apperrors.ServiceDispatcherEnrichError.AppendMessage(err, user.Email))
+		return dispatchResult{
+			outcome:      outcomeFailed,
+			user:         *user,
+			code:         apperrors.CodeOf(err),
+			reason:       err.Error(),
+			deadLettered: d.deadLetter(ctx, *user, err),
+		}, false
+	}
+	return d.validateUser(*user)
+}
+
+// validateUser reports whether user passes validation, with the result
+// listing every failing field for when it does not.
+func (d *dispatcher) validateUser(user model.User) (dispatchResult, bool) {
+	problems := user.ValidateWith(model.ValidationOptions{StrictDomain: d.cfg.EmailStrictDomain})
+	if len(problems) == 0 {
+		return dispatchResult{}, true
+	}
+	reasons := make([]interface{}, 0, len(problems))
+	for _, problem := range problems {
+		reasons = append(reasons, problem)
+	}
+	err := apperrors.ServiceDispatcherInvalidUserError.AppendMessage(reasons...)
+	d.logger.Println(err)
+	return dispatchResult{outcome: outcomeInvalid, user: user, code: err.Code, reason: err.Message}, false
+}
+
+// rejectedResult reports a user whose source record failed validation
+// against the source schema. It is still observed so change-data-capture
+// does not mistake it for a deleted user.
+func (d *dispatcher) rejectedResult(user model.User, err error) dispatchResult {
+	if d.snapshot != nil && user.Key() != "" {
+		d.snapshot.Observe(user)
+	}
+	invalidErr := apperrors.ServiceDispatcherInvalidUserError.AppendMessage(err)
+	d.logger.Println(invalidErr)
+	return dispatchResult{outcome: outcomeInvalid, user: user, code: apperrors.CodeOf(err), reason: err.Error()}
+}
+
+// postUser posts a single user. The call is bounded by the client's retry
+// policy, which times every attempt out and gives up after
+// RETRY_MAX_ELAPSED_TIME, so no deadline is added here.
+func (d *dispatcher) postUser(ctx context.Context, user model.User) dispatchResult {
+	ctx, span := d.tracer.Start(ctx, "post")
+	defer span.End()
+	startedAt := time.Now()
+	err := d.apiClient.PostUser(ctx, user)
+	postDuration := time.Since(startedAt)
+	if err != nil {
+		return d.failedResult(ctx, user, err, postDuration)
+	}
+
+	return d.postedResult(user, postDuration)
+}
+
+// postUsers posts users in one bulk call. The time it took is shared evenly
+// between the users so the report's post duration is not inflated. The span
+// of the call is linked to the span of every user, found in userCtxs. Like
+// postUser it adds no deadline: the client sends the chunks of the call one
+// after the other, each bounded by the retry policy on its own.
+func (d *dispatcher) postUsers(ctx context.Context, users []model.User, userCtxs []context.Context) []dispatchResult {
+	links := make([]trace.Link, 0, len(userCtxs))
+	for _, userCtx := range userCtxs {
+		links = append(links, trace.LinkFromContext(userCtx))
+	}
+	ctx, span := d.tracer.Start(ctx, "post batch", trace.WithLinks(links...), trace.WithAttributes(batchSizeAttribute.Int(len(users))))
+	defer span.End()
+	startedAt := time.Now()
+	errs := d.apiClient.PostUsers(ctx, users)
+	postDuration := time.Since(startedAt) / time.Duration(len(users))
+
+	results := make([]dispatchResult, 0, len(users))
+	for i, user := range users {
+		var err error
+		if i < len(errs) {
+			err = errs[i]
+		}
 		if err != nil {
-			d.logger.Error(apperrors.ServiceDispatcherPostUserError.AppendMessage(err, user))
+			results = append(results, d.failedResult(ctx, user, err, postDuration))
+			continue
 		}
+		results = append(results, d.postedResult(user, postDuration))
 	}
 
-	return nil
+	return results
+}
+
+func (d *dispatcher) postedResult(user model.User, postDuration time.Duration) dispatchResult {
+	if d.ledger != nil {
+		if err := d.ledger.Record(user.Key(), user.ContentHash()); err != nil {
+			d.logger.Error(apperrors.ServiceDispatcherLedgerError.AppendMessage(err, user))
+		}
+	}
+	if d.snapshot != nil {
+		d.snapshot.Put(user)
+	}
+	return dispatchResult{outcome: outcomePosted, user: user, postDuration: postDuration}
+}
+
+func (d *dispatcher) failedResult(ctx context.Context, user model.User, err error, postDuration time.Duration) dispatchResult {
+	if apperrors.Is(err, apperrors.SchemaValidationError) {
+		// A payload the schema rejects fails the same way on every retry, so
+		// it is reported as invalid and not dead-lettered.
+		d.logger.Println(apperrors.ServiceDispatcherInvalidUserError.AppendMessage(err, user))
+		return dispatchResult{
+			outcome:      outcomeInvalid,
+			user:         user,
+			code:         apperrors.SchemaValidationError.Code,
+			reason:       err.Error(),
+			postDuration: postDuration,
+		}
+	}
+	d.logger.Error(apperrors.ServiceDispatcherPostUserError.AppendMessage(err, user))
+	return dispatchResult{
+		outcome:      outcomeFailed,
+		user:         user,
+		code:         apperrors.CodeOf(err),
+		reason:       err.Error(),
+		postDuration: postDuration,
+		deadLettered: d.deadLetter(ctx, user, err),
+	}
 }
@@ -2,14 +2,36 @@ package service_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/client"
 	"data-enricher-dispatcher/config"
+	"data-enricher-dispatcher/deadletter"
+	"data-enricher-dispatcher/enrich"
+	"data-enricher-dispatcher/filter"
+	"data-enricher-dispatcher/ledger"
+	"data-enricher-dispatcher/metrics"
 	"data-enricher-dispatcher/model"
 	"data-enricher-dispatcher/service"
+	"data-enricher-dispatcher/snapshot"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type MockAPIClient struct {
@@ -29,11 +51,40 @@ func (m *MockAPIClient) GetUsers(ctx context.Context) ([]model.User, error) {
 	return nil, args.Error(1)
 }
 
+func (m *MockAPIClient) StreamUsers(ctx context.Context) (client.UserIterator, error) {
+	args := m.Called(ctx)
+	if users, ok := args.Get(0).(client.UserIterator); ok {
+		return users, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIClient) StreamUsersFrom(ctx context.Context, position client.Position) (client.UserIterator, error) {
+	args := m.Called(ctx, position)
+	if users, ok := args.Get(0).(client.UserIterator); ok {
+		return users, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockAPIClient) PostUser(ctx context.Context, user model.User) error {
 	args := m.Called(ctx, user)
 	return args.Error(0)
 }
 
+func (m *MockAPIClient) PostUsers(ctx context.Context, users []model.User) []error {
+	args := m.Called(ctx, users)
+	if errs, ok := args.Get(0).([]error); ok {
+		return errs
+	}
+	return make([]error, len(users))
+}
+
+func (m *MockAPIClient) DeleteUser(ctx context.Context, user model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
 type MockLogger struct {
 	mock.Mock
 }
@@ -65,7 +116,7 @@ func (m *MockLogger) Warn(v ...interface{}) {
 func TestDispatcher_Start(t *testing.T) {
 	mockClient := new(MockAPIClient)
 	mockLogger := new(MockLogger)
-	cfg := &config.Config{ExcludePostfixes: []string{"@test.com"}}
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}}
 
 	users := []model.User{
 		{Name: "John Doe", Email: "john@test.com"},
@@ -73,21 +124,902 @@ func TestDispatcher_Start(t *testing.T) {
 		{Name: "Other User", Email: "other@other.com"},
 	}
 
-	mockClient.On("GetUsers", mock.MatchedBy(func(ctx context.Context) bool {
-		_, ok := ctx.Deadline()
-		return ok
-	})).Return(users, nil)
+	mockClient.On("StreamUsers", mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx != nil
+	})).Return(client.NewSliceIterator(users), nil)
 	mockClient.On("PostUser", mock.MatchedBy(func(ctx context.Context) bool {
 		return ctx != nil
 	}), users[0]).Return(nil)
 	mockLogger.On("Println", mock.Anything).Once()
-	mockLogger.On("Info", mock.Anything).Once()
+	mockLogger.On("Info", mock.Anything).Twice()
 
 	ctx := context.Background()
 	d := service.NewDispatcher(mockClient, mockLogger, cfg)
-	err := d.Start(ctx)
+	report, err := d.Start(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, service.RunStatusSucceeded, report.Status)
+	assert.Equal(t, 3, report.Fetched)
+	assert.Equal(t, 1, report.Posted)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Equal(t, 1, report.Skipped)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, apperrors.ServiceDispatcherInvalidUserError.Code, report.Failures[0].Code)
+	}
+
+	mockClient.AssertExpectations(t)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestDispatcher_StartConcurrent(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}, DispatchConcurrency: 4}
+
+	users := make([]model.User, 0, 20)
+	for i := 0; i < 20; i++ {
+		users = append(users, model.User{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@test.com", i)})
+	}
+
+	var inFlight, maxInFlight int32
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+	mockClient.On("PostUser", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	})
+	mockLogger.On("Info", mock.Anything).Once()
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg)
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, len(users), report.Posted)
+
+	mockClient.AssertNumberOfCalls(t, "PostUser", len(users))
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(cfg.DispatchConcurrency))
+	assert.Greater(t, atomic.LoadInt32(&maxInFlight), int32(1))
+	mockLogger.AssertExpectations(t)
+}
+
+func TestDispatcher_StartCanceled(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}, DispatchConcurrency: 2}
+
+	users := []model.User{
+		{Name: "John Doe", Email: "john@test.com"},
+		{Name: "Jane Doe", Email: "jane@test.com"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+	mockLogger.On("Info", mock.Anything).Once()
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg)
+	report, err := d.Start(ctx)
+	assert.True(t, apperrors.Is(err, apperrors.ServiceDispatcherCanceledError))
+	assert.Equal(t, service.RunStatusFailed, report.Status)
+
+	mockClient.AssertNotCalled(t, "PostUser", mock.Anything, mock.Anything)
+}
+
+func TestDispatcher_StartStreamError(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	cfg := &config.Config{}
+
+	mockClient.On("StreamUsers", mock.Anything).Return(nil, errors.New("connection refused"))
+
+	mockLogger.On("Info", mock.Anything).Once()
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg)
+	report, err := d.Start(context.Background())
+	assert.True(t, apperrors.Is(err, apperrors.ServiceDispatcherGetUsersError))
+	assert.Equal(t, service.RunStatusFailed, report.Status)
+
+	mockClient.AssertNotCalled(t, "PostUser", mock.Anything, mock.Anything)
+}
+
+func TestDispatcher_StartWritesReport(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}, ReportPath: reportPath}
+
+	users := []model.User{
+		{Name: "John Doe", Email: "john@test.com"},
+		{Name: "Jane Doe", Email: "jane@test.com"},
+	}
+	postErr := apperrors.ApiClientPostUserPostError.AppendMessage("connection reset")
+
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+	mockClient.On("PostUser", mock.Anything, users[0]).Return(nil)
+	mockClient.On("PostUser", mock.Anything, users[1]).Return(postErr)
+	mockLogger.On("Error", mock.Anything).Once()
+	mockLogger.On("Info", mock.Anything).Once()
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg)
+	_, err := d.Start(context.Background())
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(reportPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var report service.RunReport
+	assert.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, service.RunStatusPartial, report.Status)
+	assert.Equal(t, 1, report.Posted)
+	assert.Equal(t, 1, report.Failed)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, service.OutcomeFailed, report.Failures[0].Outcome)
+		assert.Equal(t, "jane@test.com", report.Failures[0].Email)
+		assert.Equal(t, apperrors.ApiClientPostUserPostError.Code, report.Failures[0].Code)
+	}
+}
+
+func TestDispatcher_DeadLetterAndReplay(t *testing.T) {
+	dlqPath := filepath.Join(t.TempDir(), "dlq.jsonl")
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}, DeadLetterPath: dlqPath}
+	users := []model.User{
+		{Name: "John Doe", Email: "john@test.com"},
+		{Name: "Jane Doe", Email: "jane@test.com"},
+	}
+	postErr := &client.DeliveryError{
+		Err:        apperrors.ApiClientPostUserPostError.AppendMessage("unexpected status code: 503, attempts: 3"),
+		StatusCode: 503,
+		Attempts:   3,
+	}
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+	mockClient.On("PostUser", mock.Anything, users[0]).Return(nil)
+	mockClient.On("PostUser", mock.Anything, users[1]).Return(postErr).Once()
+	mockLogger.On("Error", mock.Anything)
+	mockLogger.On("Info", mock.Anything)
+
+	sink := deadletter.NewFileSink(dlqPath)
+	d := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithDeadLetterSink(sink))
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.DeadLettered)
+
+	entries, err := deadletter.ReadFile(dlqPath)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, users[1], entries[0].User)
+		assert.Equal(t, 503, entries[0].StatusCode)
+		assert.Equal(t, 3, entries[0].Attempts)
+		assert.Equal(t, apperrors.ApiClientPostUserPostError.Code, entries[0].ErrorCode)
+	}
+
+	mockClient.On("PostUser", mock.Anything, users[1]).Return(nil).Once()
+	report, err = d.Replay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, service.RunModeReplay, report.Mode)
+	assert.Equal(t, 1, report.Posted)
+	assert.NoError(t, sink.Close())
+
+	entries, err = deadletter.ReadFile(dlqPath)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	_, err = os.Stat(dlqPath + ".replay")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDispatcher_ReplayInterrupted(t *testing.T) {
+	dlqPath := filepath.Join(t.TempDir(), "dlq.jsonl")
+	cfg := &config.Config{DeadLetterPath: dlqPath, ShutdownGraceTimeout: time.Second}
+	users := []model.User{
+		{Name: "User 1", Email: "user1@test.com"},
+		{Name: "User 2", Email: "user2@test.com"},
+		{Name: "User 3", Email: "user3@test.com"},
+		{Name: "User 4", Email: "user4@test.com"},
+	}
+	require.NoError(t, deadletter.WriteFile(dlqPath, []deadletter.Entry{{User: users[0]}, {User: users[1]}, {User: users[2]}}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("PostUser", mock.Anything, users[0]).Run(func(mock.Arguments) { cancel() }).Return(nil).Once()
+	mockLogger.On("Info", mock.Anything)
+	mockLogger.On("Warn", mock.Anything)
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg)
+	_, err := d.Replay(ctx)
+	require.Error(t, err)
+	mockClient.AssertNotCalled(t, "PostUser", mock.Anything, users[1])
+
+	// Only the users that were not posted are left to replay, next to the
+	// ones dead-lettered in the meantime.
+	entries, err := deadletter.ReadFile(dlqPath + ".replay")
+	require.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, users[1], entries[0].User)
+		assert.Equal(t, users[2], entries[1].User)
+	}
+	require.NoError(t, deadletter.WriteFile(dlqPath, []deadletter.Entry{{User: users[3]}}))
+
+	mockClient.On("PostUser", mock.Anything, users[1]).Return(nil).Once()
+	mockClient.On("PostUser", mock.Anything, users[2]).Return(nil).Once()
+	mockClient.On("PostUser", mock.Anything, users[3]).Return(nil).Once()
+	report, err := d.Replay(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Posted)
+	mockClient.AssertExpectations(t)
+
+	_, err = os.Stat(dlqPath)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(dlqPath + ".replay")
+	assert.True(t, os.IsNotExist(err))
+}
+
+// brokenSink fails every write.
+type brokenSink struct{}
+
+func (brokenSink) Write(context.Context, deadletter.Entry) error {
+	return errors.New("disk full")
+}
+
+func (brokenSink) Close() error {
+	return nil
+}
+
+func TestDispatcher_ReplayKeepsUsersTheSinkLost(t *testing.T) {
+	dlqPath := filepath.Join(t.TempDir(), "dlq.jsonl")
+	users := []model.User{
+		{Name: "User 1", Email: "user1@test.com"},
+		{Name: "User 2", Email: "user2@test.com"},
+	}
+	require.NoError(t, deadletter.WriteFile(dlqPath, []deadletter.Entry{{User: users[0]}, {User: users[1]}}))
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("PostUser", mock.Anything, users[0]).Return(errors.New("connection reset")).Once()
+	mockClient.On("PostUser", mock.Anything, users[1]).Return(nil).Once()
+	mockLogger.On("Error", mock.Anything)
+	mockLogger.On("Info", mock.Anything)
+
+	d := service.NewDispatcher(mockClient, mockLogger, &config.Config{DeadLetterPath: dlqPath}, service.WithDeadLetterSink(brokenSink{}))
+	report, err := d.Replay(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 0, report.DeadLettered)
+
+	// The user that could not be dead-lettered again is still up for replay.
+	entries, err := deadletter.ReadFile(dlqPath + ".replay")
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, users[0], entries[0].User)
+	}
+}
+
+// TestDispatcher_StartPostsWithoutDeadline checks the dispatcher leaves the
+// bound of a post to the client's retry policy, so a long Retry-After is
+// waited out rather than cut short.
+func TestDispatcher_StartPostsWithoutDeadline(t *testing.T) {
+	users := []model.User{{Name: "John Doe", Email: "john@test.com"}}
+	noDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return !ok
+	})
+
+	tests := []struct {
+		name      string
+		batchSize int
+		method    string
+	}{
+		{name: "single post", method: "PostUser"},
+		{name: "bulk post", batchSize: 2, method: "PostUsers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(MockAPIClient)
+			mockLogger := new(MockLogger)
+			mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+			mockClient.On("PostUser", noDeadline, users[0]).Return(nil)
+			mockClient.On("PostUsers", noDeadline, users).Return(nil)
+			mockLogger.On("Info", mock.Anything)
+
+			d := service.NewDispatcher(mockClient, mockLogger, &config.Config{PostBatchSize: tt.batchSize})
+			report, err := d.Start(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 1, report.Posted)
+			mockClient.AssertNumberOfCalls(t, tt.method, 1)
+		})
+	}
+}
+
+func TestDispatcher_StartBatches(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}, PostBatchSize: 2}
+
+	users := []model.User{
+		{Name: "User 1", Email: "user1@test.com"},
+		{Name: "User 2", Email: "user2@other.com"},
+		{Name: "User 3", Email: "user3@test.com"},
+		{Name: "User 4", Email: "user4@test.com"},
+		{Name: "User 5", Email: "user5@test.com"},
+	}
+	itemErr := apperrors.ApiClientPostUsersItemError.AppendMessage("item status: 422, error: duplicate")
+
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+	mockClient.On("PostUsers", mock.Anything, []model.User{users[0]}).Return([]error{nil}).Once()
+	mockClient.On("PostUsers", mock.Anything, []model.User{users[2], users[3]}).Return([]error{nil, itemErr}).Once()
+	mockClient.On("PostUsers", mock.Anything, []model.User{users[4]}).Return([]error{nil}).Once()
+	mockLogger.On("Info", mock.Anything)
+	mockLogger.On("Error", mock.Anything).Once()
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg)
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, report.Fetched)
+	assert.Equal(t, 3, report.Posted)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Failed)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, "user4@test.com", report.Failures[0].Email)
+		assert.Equal(t, apperrors.ApiClientPostUsersItemError.Code, report.Failures[0].Code)
+	}
+
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "PostUser", mock.Anything, mock.Anything)
+}
+
+func TestDispatcher_StartSkipsDeliveredUsers(t *testing.T) {
+	l, err := ledger.Open(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close()
+
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}}
+	delivered := model.User{Name: "John Doe", Email: "john@test.com"}
+	changed := model.User{Name: "Jane Doe", Email: "jane@test.com"}
+	assert.NoError(t, l.Record(delivered.Key(), delivered.ContentHash()))
+	previous := model.User{Name: "Jane Old", Email: "jane@test.com"}
+	assert.NoError(t, l.Record(previous.Key(), previous.ContentHash()))
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator([]model.User{delivered, changed}), nil)
+	mockClient.On("PostUser", mock.Anything, changed).Return(nil).Once()
+	mockLogger.On("Debug", mock.Anything).Once()
+	mockLogger.On("Info", mock.Anything).Once()
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithLedger(l))
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Equal(t, 1, report.Posted)
+	assert.True(t, l.Has(changed.Key(), changed.ContentHash()))
+
+	mockClient.AssertExpectations(t)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestDispatcher_StartRedeliversRevertedUsers(t *testing.T) {
+	l, err := ledger.Open(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer l.Close()
+
+	// The user changes from a to b and back to a: every run must post it, as
+	// the sink holds b when the third run starts.
+	a := model.User{Name: "Jane Doe", Email: "jane@test.com"}
+	b := model.User{Name: "Jane Smith", Email: "jane@test.com"}
+	for i, user := range []model.User{a, b, a} {
+		mockClient := new(MockAPIClient)
+		mockLogger := new(MockLogger)
+		mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator([]model.User{user}), nil)
+		mockClient.On("PostUser", mock.Anything, user).Return(nil).Once()
+		mockLogger.On("Info", mock.Anything)
+
+		report, err := service.NewDispatcher(mockClient, mockLogger, &config.Config{}, service.WithLedger(l)).Start(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Posted, "run %d", i+1)
+		assert.Equal(t, 0, report.Duplicates, "run %d", i+1)
+	}
+}
+
+func TestDispatcher_StartChangeDataCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	store, err := snapshot.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	unchanged := model.User{Name: "John Doe", Email: "john@test.com"}
+	updated := model.User{Name: "Jane Doe", Email: "jane@test.com"}
+	created := model.User{Name: "Jim Doe", Email: "jim@test.com"}
+	deleted := model.User{Name: "Joe Doe", Email: "joe@test.com"}
+	undeletable := model.User{Name: "Jill Doe", Email: "jill@test.com"}
+	store.Put(unchanged)
+	store.Put(model.User{Name: "Jane Old", Email: "JANE@test.com"})
+	store.Put(deleted)
+	store.Put(undeletable)
+
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}, DeleteUsersURL: "http://sink/users/{key}"}
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator([]model.User{unchanged, updated, created}), nil)
+	mockClient.On("PostUser", mock.Anything, updated).Return(nil).Once()
+	mockClient.On("PostUser", mock.Anything, created).Return(nil).Once()
+	mockClient.On("DeleteUser", mock.Anything, deleted).Return(nil).Once()
+	mockClient.On("DeleteUser", mock.Anything, undeletable).Return(errors.New("boom")).Once()
+	mockLogger.On("Debug", mock.Anything).Once()
+	mockLogger.On("Error", mock.Anything).Once()
+	mockLogger.On("Info", mock.Anything).Once()
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithSnapshot(store))
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, service.RunStatusPartial, report.Status)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, 2, report.Deleted)
+	assert.Equal(t, 1, report.DeleteFailed)
+	assert.Equal(t, 2, report.Posted)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, service.OutcomeDeleteFailed, report.Failures[0].Outcome)
+	}
+
+	// The saved snapshot holds the current users plus the one whose deletion
+	// must be retried.
+	reopened, err := snapshot.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, user := range []model.User{unchanged, updated, created} {
+		assert.Equal(t, snapshot.Unchanged, reopened.Classify(user))
+		reopened.Observe(user)
+	}
+	assert.Equal(t, []model.User{undeletable}, reopened.Missing())
+
+	mockClient.AssertExpectations(t)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestDispatcher_StartForgetsWithoutDeleteEndpoint(t *testing.T) {
+	store, err := snapshot.Open(filepath.Join(t.TempDir(), "snapshot.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	kept := model.User{Name: "John Doe", Email: "john@test.com"}
+	gone := model.User{Name: "Joe Doe", Email: "joe@test.com"}
+	store.Put(kept)
+	store.Put(gone)
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator([]model.User{kept}), nil)
+	mockLogger.On("Debug", mock.Anything)
+	mockLogger.On("Info", mock.Anything)
+
+	d := service.NewDispatcher(mockClient, mockLogger, &config.Config{}, service.WithSnapshot(store))
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.Deleted)
+	assert.Equal(t, 1, report.Forgotten)
+	assert.Empty(t, store.Missing())
+
+	mockClient.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+}
+
+type enricherFunc func(ctx context.Context, user *model.User) error
+
+func (f enricherFunc) Enrich(ctx context.Context, user *model.User) error {
+	return f(ctx, user)
+}
+
+func TestPrepareUser(t *testing.T) {
+	chain := enrich.NewChain(enrich.Stage{Name: "normalize_name", Enricher: enrich.NormalizeName{}, Policy: enrich.PolicyFail})
+	user := model.User{Name: "john doe", Email: " John@Test.com "}
+
+	err := service.PrepareUser(context.Background(), &config.Config{EmailNormalize: true}, chain, &user)
+	assert.NoError(t, err)
+	assert.Equal(t, model.User{Name: "John Doe", Email: "john@test.com"}, user)
+}
+
+func TestDispatcher_StartEnriches(t *testing.T) {
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}}
+	john := model.User{Name: "john doe", Email: "john@test.com"}
+	skipped := model.User{Name: "Jane Doe", Email: "jane@test.com"}
+	lookup := enricherFunc(func(_ context.Context, user *model.User) error {
+		if user.Email == skipped.Email {
+			return errors.New("not in reference table")
+		}
+		return nil
+	})
+	chain := enrich.NewChain(
+		enrich.Stage{Name: "normalize_name", Enricher: enrich.NormalizeName{}, Policy: enrich.PolicyFail},
+		enrich.Stage{Name: "email_domain", Enricher: enrich.EmailDomain{}, Policy: enrich.PolicyContinue},
+		enrich.Stage{Name: "lookup", Enricher: lookup, Policy: enrich.PolicySkip},
+	)
+
+	enriched := model.User{Name: "John Doe", Email: "john@test.com", Extra: map[string]json.RawMessage{"email_domain": json.RawMessage(`"test.com"`)}}
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator([]model.User{john, skipped}), nil)
+	mockClient.On("PostUser", mock.Anything, enriched).Return(nil).Once()
+	mockLogger.On("Info", mock.Anything)
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithEnrichers(chain))
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Posted)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Nil(t, john.Extra, "enrichment must not write through to the source user")
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestDispatcher_StartEnrichFailure(t *testing.T) {
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}}
+	user := model.User{Name: "John Doe", Email: "john@test.com"}
+	failing := enricherFunc(func(context.Context, *model.User) error {
+		return apperrors.EnrichNotFoundError.AppendMessage("john@test.com")
+	})
+	chain := enrich.NewChain(enrich.Stage{Name: "lookup", Enricher: failing, Policy: enrich.PolicyFail})
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator([]model.User{user}), nil)
+	mockLogger.On("Error", mock.Anything).Once()
+	mockLogger.On("Info", mock.Anything).Once()
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithEnrichers(chain))
+	report, err := d.Start(context.Background())
 	assert.NoError(t, err)
+	assert.Equal(t, service.RunStatusPartial, report.Status)
+	assert.Equal(t, 1, report.Failed)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, apperrors.EnrichNotFoundError.Code, report.Failures[0].Code)
+	}
+
+	mockClient.AssertNotCalled(t, "PostUser", mock.Anything, mock.Anything)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestDispatcher_StartFilters(t *testing.T) {
+	cfg := &config.Config{}
+	userFilter, err := filter.New(`email domain "example.com"`, `name matches "^Test"`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	included := model.User{Name: "John Doe", Email: "john@example.com"}
+	excluded := model.User{Name: "Test User", Email: "test@example.com"}
+	other := model.User{Name: "Jane Doe", Email: "jane@other.com"}
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator([]model.User{included, excluded, other}), nil)
+	mockClient.On("PostUser", mock.Anything, included).Return(nil).Once()
+	mockLogger.On("Info", mock.Anything).Times(3)
+
+	d := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithFilter(userFilter))
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Posted)
+	assert.Equal(t, 2, report.Skipped)
+
+	mockClient.AssertExpectations(t)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestDispatcher_StartEmailSuffixes(t *testing.T) {
+	biz := model.User{Name: "Leanne Graham", Email: "Sincere@April.biz"}
+	com := model.User{Name: "Ervin Howell", Email: "Shanna@melissa.tv.com"}
+	lookalike := model.User{Name: "Clementine Bauch", Email: "Nathan@notbiz.net"}
+	users := []model.User{biz, com, lookalike}
+
+	tests := []struct {
+		name   string
+		cfg    *config.Config
+		posted []model.User
+	}{
+		{name: "no suffixes", cfg: &config.Config{}, posted: users},
+		{name: "exclude", cfg: &config.Config{ExcludeEmailSuffixes: []string{".biz"}}, posted: []model.User{com, lookalike}},
+		{name: "include", cfg: &config.Config{IncludeEmailSuffixes: []string{"BIZ"}}, posted: []model.User{biz}},
+		{name: "include and exclude", cfg: &config.Config{IncludeEmailSuffixes: []string{".com", ".net"}, ExcludeEmailSuffixes: []string{"@notbiz.net"}}, posted: []model.User{com}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(MockAPIClient)
+			mockLogger := new(MockLogger)
+			mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+			for _, user := range tt.posted {
+				mockClient.On("PostUser", mock.Anything, user).Return(nil).Once()
+			}
+			mockLogger.On("Info", mock.Anything)
 
+			report, err := service.NewDispatcher(mockClient, mockLogger, tt.cfg).Start(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.posted), report.Posted)
+			assert.Equal(t, len(users)-len(tt.posted), report.Skipped)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDispatcher_StartValidatesEmails(t *testing.T) {
+	users := []model.User{
+		{Name: " Leanne Graham", Email: "Sincere@April.biz "},
+		{Name: "Ervin Howell", Email: "not-an-email"},
+		{Name: "Clementine Bauch", Email: "nathan@localhost"},
+	}
+
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		posted  []model.User
+		invalid int
+	}{
+		{
+			name:    "normalized",
+			cfg:     &config.Config{EmailNormalize: true},
+			posted:  []model.User{{Name: "Leanne Graham", Email: "sincere@april.biz"}, users[2]},
+			invalid: 1,
+		},
+		{
+			name:    "not normalized",
+			cfg:     &config.Config{},
+			posted:  []model.User{users[2]},
+			invalid: 2,
+		},
+		{
+			name:    "strict domain",
+			cfg:     &config.Config{EmailNormalize: true, EmailStrictDomain: true},
+			posted:  []model.User{{Name: "Leanne Graham", Email: "sincere@april.biz"}},
+			invalid: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(MockAPIClient)
+			mockLogger := new(MockLogger)
+			mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+			for _, user := range tt.posted {
+				mockClient.On("PostUser", mock.Anything, user).Return(nil).Once()
+			}
+			mockLogger.On("Println", mock.Anything)
+			mockLogger.On("Info", mock.Anything)
+
+			report, err := service.NewDispatcher(mockClient, mockLogger, tt.cfg).Start(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.posted), report.Posted)
+			assert.Equal(t, tt.invalid, report.Invalid)
+			for _, failure := range report.Failures {
+				assert.Equal(t, apperrors.ServiceDispatcherInvalidUserError.Code, failure.Code)
+				assert.Contains(t, failure.Reason, "email: ")
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+// rejectingIterator flags the users at the given indexes as rejected by the
+// source schema.
+type rejectingIterator struct {
+	client.UserIterator
+	rejected map[int]bool
+	index    int
+}
+
+func (it *rejectingIterator) Next() bool {
+	it.index++
+	return it.UserIterator.Next()
+}
+
+func (it *rejectingIterator) Rejected() error {
+	if it.rejected[it.index-1] {
+		return apperrors.SchemaValidationError.AppendMessage("/id: expected integer, but got string")
+	}
+	return nil
+}
+
+func TestDispatcher_StartSchemaValidation(t *testing.T) {
+	users := []model.User{
+		{Name: "Leanne Graham", Email: "sincere@april.biz"},
+		{Name: "Ervin Howell", Email: "shanna@melissa.tv"},
+		{Name: "Clementine Bauch", Email: "nathan@yesenia.net"},
+	}
+	payloadErr := apperrors.ApiClientPostUserMarshalError.AppendMessage(apperrors.SchemaValidationError.AppendMessage("/: missing properties: 'id'"))
+
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	sink := deadletter.NewFileSink(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	mockClient.On("StreamUsers", mock.Anything).Return(&rejectingIterator{UserIterator: client.NewSliceIterator(users), rejected: map[int]bool{1: true}}, nil)
+	mockClient.On("PostUser", mock.Anything, users[0]).Return(nil).Once()
+	mockClient.On("PostUser", mock.Anything, users[2]).Return(payloadErr).Once()
+	mockLogger.On("Println", mock.Anything).Twice()
+	mockLogger.On("Info", mock.Anything)
+
+	report, err := service.NewDispatcher(mockClient, mockLogger, &config.Config{}, service.WithDeadLetterSink(sink)).Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, service.RunStatusSucceeded, report.Status)
+	assert.Equal(t, 3, report.Fetched)
+	assert.Equal(t, 1, report.Posted)
+	assert.Equal(t, 2, report.Invalid)
+	assert.Equal(t, 0, report.DeadLettered)
+	if assert.Len(t, report.Failures, 2) {
+		assert.Equal(t, users[1].Email, report.Failures[0].Email)
+		for _, failure := range report.Failures {
+			assert.Equal(t, service.OutcomeInvalid, failure.Outcome)
+			assert.Equal(t, apperrors.SchemaValidationError.Code, failure.Code)
+		}
+	}
 	mockClient.AssertExpectations(t)
 	mockLogger.AssertExpectations(t)
 }
+
+func TestDispatcher_StartDrainsPostsInFlight(t *testing.T) {
+	users := []model.User{
+		{Name: "John Doe", Email: "john@test.com"},
+		{Name: "Jane Doe", Email: "jane@test.com"},
+	}
+
+	tests := []struct {
+		name          string
+		grace         time.Duration
+		expectPostErr bool
+	}{
+		{name: "completes within the grace timeout", grace: time.Second},
+		{name: "cancelled without a grace timeout", expectPostErr: true},
+		{name: "cancelled after the grace timeout", grace: time.Millisecond, expectPostErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(MockAPIClient)
+			mockLogger := new(MockLogger)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var postErr error
+			mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+			mockClient.On("PostUser", mock.Anything, users[0]).Run(func(args mock.Arguments) {
+				postCtx := args.Get(0).(context.Context)
+				cancel()
+				select {
+				case <-postCtx.Done():
+				case <-time.After(50 * time.Millisecond):
+				}
+				postErr = postCtx.Err()
+			}).Return(nil).Once()
+			mockLogger.On("Info", mock.Anything)
+			mockLogger.On("Warn", mock.Anything)
+
+			d := service.NewDispatcher(mockClient, mockLogger, &config.Config{ShutdownGraceTimeout: tt.grace})
+			report, err := d.Start(ctx)
+			assert.True(t, apperrors.Is(err, apperrors.ServiceDispatcherCanceledError))
+			assert.Equal(t, service.RunStatusFailed, report.Status)
+			assert.Equal(t, tt.expectPostErr, postErr != nil)
+			mockClient.AssertNotCalled(t, "PostUser", mock.Anything, users[1])
+		})
+	}
+}
+
+func TestDispatcher_StartRecordsMetrics(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	users := []model.User{
+		{Name: "John Doe", Email: "john@test.com"},
+		{Name: "", Email: "jane@test.com"},
+		{Name: "Other User", Email: "other@other.com"},
+	}
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+	mockClient.On("PostUser", mock.Anything, users[0]).Return(nil)
+	mockLogger.On("Println", mock.Anything)
+	mockLogger.On("Info", mock.Anything)
+
+	m := metrics.New("test")
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}, DispatchConcurrency: 2}
+	_, err := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithMetrics(m)).Start(context.Background())
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`dispatcher_users_fetched_total{environment="test"} 3`,
+		`dispatcher_users_total{environment="test",outcome="posted"} 1`,
+		`dispatcher_users_total{environment="test",outcome="invalid"} 1`,
+		`dispatcher_users_total{environment="test",outcome="skipped"} 1`,
+		`dispatcher_users_in_flight{environment="test"} 0`,
+		`dispatcher_runs_total{environment="test",mode="dispatch",status="succeeded"} 1`,
+	} {
+		assert.True(t, strings.Contains(body, want), "expected %s in:\n%s", want, body)
+	}
+}
+
+func TestDispatcher_StartRecordsSpans(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	users := []model.User{
+		{Name: "John Doe", Email: "john@test.com"},
+		{Name: "Other User", Email: "other@other.com"},
+	}
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+	mockClient.On("PostUser", mock.Anything, users[0]).Return(nil)
+	mockLogger.On("Info", mock.Anything)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cfg := &config.Config{IncludeEmailSuffixes: []string{"@test.com"}}
+	_, err := service.NewDispatcher(mockClient, mockLogger, cfg, service.WithTracerProvider(provider)).Start(context.Background())
+	assert.NoError(t, err)
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	if !assert.Len(t, spans["dispatch dispatch"], 1) || !assert.Len(t, spans["dispatch user"], 2) {
+		return
+	}
+	run := spans["dispatch dispatch"][0]
+	assert.Contains(t, run.Attributes(), attribute.String("dispatch.status", service.RunStatusSucceeded))
+
+	outcomes := map[string]string{}
+	for _, user := range spans["dispatch user"] {
+		assert.Equal(t, run.SpanContext().SpanID(), user.Parent().SpanID())
+		attributes := attribute.NewSet(user.Attributes()...)
+		key, _ := attributes.Value("user.key")
+		outcome, _ := attributes.Value("dispatch.outcome")
+		outcomes[key.AsString()] = outcome.AsString()
+	}
+	assert.Equal(t, map[string]string{"john@test.com": "posted", "other@other.com": "skipped"}, outcomes)
+	assert.Len(t, spans["filter"], 2)
+	if assert.Len(t, spans["post"], 1) {
+		assert.Contains(t, []string{spans["dispatch user"][0].SpanContext().SpanID().String(), spans["dispatch user"][1].SpanContext().SpanID().String()},
+			spans["post"][0].Parent().SpanID().String())
+	}
+}
+
+func TestDispatcher_Status(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockLogger := new(MockLogger)
+	users := []model.User{
+		{Name: "John Doe", Email: "john@test.com"},
+		{Name: "Jane Doe", Email: "jane@test.com"},
+	}
+	d := service.NewDispatcher(mockClient, mockLogger, &config.Config{})
+	reporter := d.(service.StatusReporter)
+	assert.Equal(t, service.Status{Phase: service.PhaseIdle}, reporter.Status())
+
+	var during service.Status
+	mockClient.On("StreamUsers", mock.Anything).Return(client.NewSliceIterator(users), nil)
+	mockClient.On("PostUser", mock.Anything, users[0]).Return(nil).Once()
+	mockClient.On("PostUser", mock.Anything, users[1]).Run(func(mock.Arguments) {
+		during = reporter.Status()
+	}).Return(nil).Once()
+	mockLogger.On("Info", mock.Anything)
+
+	report, err := d.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, service.PhaseDispatching, during.Phase)
+	assert.Equal(t, service.RunModeDispatch, during.Mode)
+	assert.Equal(t, 2, during.Fetched)
+	assert.Equal(t, 1, during.InFlight)
+	// The result of the first user may not have been recorded yet.
+	assert.LessOrEqual(t, during.Finished, 1)
+
+	after := reporter.Status()
+	assert.Equal(t, service.PhaseIdle, after.Phase)
+	assert.Zero(t, after.InFlight)
+	assert.Same(t, report, after.LastRun)
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/client"
+	"data-enricher-dispatcher/deadletter"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	replaySuffix          = ".replay"
+	deadLetterNotSetError = "DEAD_LETTER_PATH is not configured"
+	singleAttempt         = 1
+)
+
+// deadLetter hands a user that failed to post to the dead-letter sink and
+// reports whether it was recorded.
+func (d *dispatcher) deadLetter(ctx context.Context, user model.User, postErr error) bool {
+	if d.deadLetters == nil {
+		return false
+	}
+
+	entry := deadletter.Entry{
+		User:      user,
+		ErrorCode: apperrors.CodeOf(postErr),
+		Error:     postErr.Error(),
+		Attempts:  singleAttempt,
+		FailedAt:  time.Now(),
+	}
+	var deliveryErr *client.DeliveryError
+	if errors.As(postErr, &deliveryErr) {
+		entry.StatusCode = deliveryErr.StatusCode
+		entry.Attempts = deliveryErr.Attempts
+	}
+
+	if err := d.deadLetters.Write(ctx, entry); err != nil {
+		d.logger.Error(apperrors.ServiceDispatcherDeadLetterError.AppendMessage(err, user))
+		return false
+	}
+	return true
+}
+
+// replay moves the entries of the dead-letter file aside and dispatches their
+// users again. Users failing once more are dead-lettered into a fresh file at
+// the configured path. When the replay is interrupted, the moved file is cut
+// down to the entries that did not reach an outcome, which the next replay
+// picks up along with the users dead-lettered in the meantime.
+func (d *dispatcher) replay(ctx context.Context, report *RunReport) error {
+	path := d.cfg.DeadLetterPath
+	if path == "" {
+		return apperrors.ServiceDispatcherReplayError.AppendMessage(deadLetterNotSetError)
+	}
+
+	if rotator, ok := d.deadLetters.(deadletter.Rotator); ok {
+		if err := rotator.Rotate(); err != nil {
+			return apperrors.ServiceDispatcherReplayError.AppendMessage(err)
+		}
+	}
+
+	replayPath := path + replaySuffix
+	entries, err := moveDeadLetters(path, replayPath)
+	if err != nil {
+		return apperrors.ServiceDispatcherReplayError.AppendMessage(err)
+	}
+	users := make([]model.User, 0, len(entries))
+	for _, entry := range entries {
+		users = append(users, entry.User)
+	}
+
+	tracker := &replayTracker{acked: make(map[int]bool, len(entries))}
+	dispatchErr := d.dispatch(ctx, client.NewSliceIterator(users), report, tracker)
+
+	var pending []deadletter.Entry
+	for seq, entry := range entries {
+		if !tracker.acked[seq] {
+			pending = append(pending, entry)
+		}
+	}
+	if err := deadletter.WriteFile(replayPath, pending); err != nil {
+		d.logger.Error(apperrors.ServiceDispatcherReplayError.AppendMessage(err))
+	}
+	return dispatchErr
+}
+
+// moveDeadLetters appends the entries of the dead-letter file at path to the
+// file at replayPath, left behind by an interrupted replay if any, and
+// returns all of them. The dead-letter file is only removed once its entries
+// are safe in replayPath.
+func moveDeadLetters(path, replayPath string) ([]deadletter.Entry, error) {
+	if _, err := os.Stat(replayPath); errors.Is(err, fs.ErrNotExist) {
+		if err := os.Rename(path, replayPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		return deadletter.ReadFile(replayPath)
+	}
+
+	entries, err := deadletter.ReadFile(replayPath)
+	if err != nil {
+		return nil, err
+	}
+	added, err := deadletter.ReadFile(path)
+	if err != nil || len(added) == 0 {
+		return entries, err
+	}
+	entries = append(entries, added...)
+	if err := deadletter.WriteFile(replayPath, entries); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// replayTracker records which entries of a replay reached an outcome. A
+// failed user only counts once it is dead-lettered again, so an entry the
+// sink could not take stays in the replay file. Only the goroutine collecting
+// the results acknowledges users, and acked is read once the dispatch
+// returned, so it needs no lock.
+type replayTracker struct {
+	acked map[int]bool
+}
+
+func (t *replayTracker) read(int, client.UserIterator) {}
+
+func (t *replayTracker) acknowledge(result dispatchResult) {
+	if result.outcome == outcomeFailed && !result.deadLettered {
+		return
+	}
+	t.acked[result.seq] = true
+}
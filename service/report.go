@@ -0,0 +1,235 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"data-enricher-dispatcher/model"
+	"data-enricher-dispatcher/snapshot"
+)
+
+const (
+	RunStatusSucceeded = "succeeded"
+	RunStatusPartial   = "partial"
+	RunStatusFailed    = "failed"
+
+	RunModeDispatch = "dispatch"
+	RunModeReplay   = "replay"
+	RunModeResume   = "resume"
+
+	OutcomeInvalid      = "invalid"
+	OutcomeFailed       = "failed"
+	OutcomeDeleteFailed = "delete_failed"
+)
+
+// RunReport summarizes a single dispatcher run.
+type RunReport struct {
+	Mode         string    `json:"mode"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	Duration     Duration  `json:"duration"`
+	PostDuration Duration  `json:"post_duration"`
+	// RateLimitWait is the time posts spent waiting on the client rate limiter.
+	RateLimitWait Duration `json:"rate_limit_wait"`
+	Fetched       int      `json:"fetched"`
+	Posted        int      `json:"posted"`
+	Skipped       int      `json:"skipped"`
+	// Duplicates are users skipped because the ledger shows their unchanged
+	// content was delivered by an earlier run.
+	Duplicates   int `json:"duplicates"`
+	Invalid      int `json:"invalid"`
+	Failed       int `json:"failed"`
+	DeadLettered int `json:"dead_lettered"`
+	// Created, Updated, Unchanged and Deleted are the change-data-capture
+	// diff against the previous snapshot. They stay zero outside that mode.
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Deleted   int `json:"deleted"`
+	// Forgotten counts users that disappeared from the source while no delete
+	// endpoint was configured. They are dropped from the snapshot without the
+	// sink being told.
+	Forgotten int `json:"forgotten"`
+	// ResumedFrom is the number of users handled by the interrupted runs a
+	// resumed run picked up from. They are not counted as fetched again.
+	ResumedFrom int `json:"resumed_from,omitempty"`
+	// DeleteFailed counts deleted users the sink could not be told about.
+	// They stay in the snapshot and are retried by the next run.
+	DeleteFailed int       `json:"delete_failed"`
+	Failures     []Failure `json:"failures,omitempty"`
+}
+
+// Failure records why a single user was not delivered.
+type Failure struct {
+	Outcome string `json:"outcome"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Code    string `json:"code"`
+	Reason  string `json:"reason"`
+}
+
+// Duration marshals as a human readable string such as "1m30.5s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+type dispatchOutcome int
+
+const (
+	outcomePosted dispatchOutcome = iota
+	outcomeSkipped
+	outcomeDuplicate
+	outcomeInvalid
+	outcomeFailed
+	outcomeUnchanged
+	outcomeDeleted
+	outcomeDeleteFailed
+	outcomeForgotten
+)
+
+var outcomeNames = [...]string{
+	outcomePosted:       "posted",
+	outcomeSkipped:      "skipped",
+	outcomeDuplicate:    "duplicate",
+	outcomeInvalid:      OutcomeInvalid,
+	outcomeFailed:       OutcomeFailed,
+	outcomeUnchanged:    "unchanged",
+	outcomeDeleted:      "deleted",
+	outcomeDeleteFailed: OutcomeDeleteFailed,
+	outcomeForgotten:    "forgotten",
+}
+
+func (o dispatchOutcome) String() string {
+	return outcomeNames[o]
+}
+
+// dispatchResult is what a worker reports back for a single user.
+type dispatchResult struct {
+	outcome      dispatchOutcome
+	user         model.User
+	code         string
+	reason       string
+	postDuration time.Duration
+	deadLettered bool
+	change       snapshot.Change
+	// seq is the order in which the user was read from the source.
+	seq int
+}
+
+func newRunReport(mode string, startedAt time.Time) *RunReport {
+	return &RunReport{Mode: mode, StartedAt: startedAt}
+}
+
+func (r *RunReport) add(result dispatchResult) {
+	r.PostDuration += Duration(result.postDuration)
+	if result.deadLettered {
+		r.DeadLettered++
+	}
+	switch result.change {
+	case snapshot.Created:
+		r.Created++
+	case snapshot.Updated:
+		r.Updated++
+	}
+	switch result.outcome {
+	case outcomePosted:
+		r.Posted++
+	case outcomeSkipped:
+		r.Skipped++
+	case outcomeDuplicate:
+		r.Duplicates++
+	case outcomeInvalid:
+		r.Invalid++
+		r.addFailure(OutcomeInvalid, result)
+	case outcomeFailed:
+		r.Failed++
+		r.addFailure(OutcomeFailed, result)
+	case outcomeUnchanged:
+		r.Unchanged++
+	case outcomeDeleted:
+		r.Deleted++
+	case outcomeDeleteFailed:
+		r.Deleted++
+		r.DeleteFailed++
+		r.addFailure(OutcomeDeleteFailed, result)
+	case outcomeForgotten:
+		r.Forgotten++
+	}
+}
+
+func (r *RunReport) addFailure(outcome string, result dispatchResult) {
+	r.Failures = append(r.Failures, Failure{
+		Outcome: outcome,
+		Name:    result.user.Name,
+		Email:   result.user.Email,
+		Code:    result.code,
+		Reason:  result.reason,
+	})
+}
+
+// total returns the number of fetched users that reached an outcome.
+func (r *RunReport) total() int {
+	return r.Posted + r.Skipped + r.Duplicates + r.Unchanged + r.Invalid + r.Failed
+}
+
+// finish stamps the end of the run and derives its status from err and the
+// failure counts.
+func (r *RunReport) finish(finishedAt time.Time, err error) {
+	r.FinishedAt = finishedAt
+	r.Duration = Duration(finishedAt.Sub(r.StartedAt))
+	switch {
+	case err != nil:
+		r.Status = RunStatusFailed
+		r.Error = err.Error()
+	case r.Failed > 0 || r.DeleteFailed > 0:
+		r.Status = RunStatusPartial
+	default:
+		r.Status = RunStatusSucceeded
+	}
+}
+
+// WriteFile stores the report as indented JSON, replacing path atomically so
+// readers never observe a partially written report.
+func (r *RunReport) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		//nolint:errcheck
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// Phases of the dispatcher reported by Status.
+const (
+	PhaseIdle        = "idle"
+	PhaseDispatching = "dispatching"
+	PhaseDeleting    = "deleting"
+)
+
+// Status is what the dispatcher is doing at a point in time.
+type Status struct {
+	Phase string `json:"phase"`
+	// Mode and StartedAt describe the run under way, if any.
+	Mode      string    `json:"mode,omitempty"`
+	StartedAt time.Time `json:"started_at,omitzero"`
+	// Fetched, InFlight and Finished count the users of the run under way
+	// read from the source, handed to a worker and having reached an
+	// outcome.
+	Fetched  int `json:"fetched"`
+	InFlight int `json:"in_flight"`
+	Finished int `json:"finished"`
+	// LastRun is the report of the last run that ended.
+	LastRun *RunReport `json:"last_run,omitempty"`
+}
+
+// StatusReporter is implemented by dispatchers that keep a Status.
+type StatusReporter interface {
+	Status() Status
+}
+
+// runState holds the Status of a dispatcher. Runs never overlap, so a single
+// one is enough.
+type runState struct {
+	mu     sync.Mutex
+	status Status
+}
+
+func (s *runState) update(change func(*Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(&s.status)
+}
+
+func (d *dispatcher) Status() Status {
+	d.state.mu.Lock()
+	defer d.state.mu.Unlock()
+	return d.state.status
+}
+
+func (d *dispatcher) beginRun(mode string, startedAt time.Time) {
+	d.state.update(func(status *Status) {
+		*status = Status{Phase: PhaseDispatching, Mode: mode, StartedAt: startedAt, LastRun: status.LastRun}
+	})
+}
+
+func (d *dispatcher) endRun(report *RunReport) {
+	d.state.update(func(status *Status) {
+		*status = Status{Phase: PhaseIdle, LastRun: report}
+	})
+}
+
+func (d *dispatcher) setPhase(phase string) {
+	d.state.update(func(status *Status) {
+		status.Phase = phase
+	})
+}
+
+// userFetched counts a user read from the source.
+func (d *dispatcher) userFetched() {
+	d.metrics.UserFetched()
+	d.state.update(func(status *Status) {
+		status.Fetched++
+	})
+}
+
+// usersInFlight moves the count of users handed to a worker by delta.
+func (d *dispatcher) usersInFlight(delta int) {
+	d.metrics.UsersInFlight(delta)
+	d.state.update(func(status *Status) {
+		status.InFlight += delta
+	})
+}
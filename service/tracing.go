@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	"data-enricher-dispatcher/model"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "data-enricher-dispatcher/service"
+
+// Span attributes recorded by the dispatcher.
+const (
+	modeAttribute      = attribute.Key("dispatch.mode")
+	statusAttribute    = attribute.Key("dispatch.status")
+	fetchedAttribute   = attribute.Key("dispatch.fetched")
+	postedAttribute    = attribute.Key("dispatch.posted")
+	failedAttribute    = attribute.Key("dispatch.failed")
+	invalidAttribute   = attribute.Key("dispatch.invalid")
+	batchSizeAttribute = attribute.Key("dispatch.batch_size")
+	outcomeAttribute   = attribute.Key("dispatch.outcome")
+	userKeyAttribute   = attribute.Key("user.key")
+	errorCodeAttribute = attribute.Key("error.code")
+)
+
+// endRunSpan records how the run of report ended on span and ends it.
+func endRunSpan(span trace.Span, report *RunReport) {
+	span.SetAttributes(
+		statusAttribute.String(report.Status),
+		fetchedAttribute.Int(report.Fetched),
+		postedAttribute.Int(report.Posted),
+		failedAttribute.Int(report.Failed),
+		invalidAttribute.Int(report.Invalid),
+	)
+	if report.Status != RunStatusSucceeded {
+		span.SetStatus(codes.Error, report.Error)
+	}
+	span.End()
+}
+
+// startUserSpan starts the span covering every stage user goes through.
+func (d *dispatcher) startUserSpan(ctx context.Context, user model.User) (context.Context, trace.Span) {
+	return d.tracer.Start(ctx, "dispatch user", trace.WithAttributes(userKeyAttribute.String(user.Key())))
+}
+
+// endUserSpan records the outcome of a user on span and ends it. Failed and
+// invalid users mark the span as an error.
+func endUserSpan(span trace.Span, result dispatchResult) {
+	span.SetAttributes(outcomeAttribute.String(result.outcome.String()))
+	switch result.outcome {
+	case outcomeInvalid, outcomeFailed:
+		span.SetAttributes(errorCodeAttribute.String(result.code))
+		span.SetStatus(codes.Error, result.reason)
+	}
+	span.End()
+}
+
+// endStageSpan ends the span of a stage that stopped the user with result,
+// or let it through when ok.
+func endStageSpan(span trace.Span, result dispatchResult, ok bool) {
+	if !ok {
+		span.SetAttributes(outcomeAttribute.String(result.outcome.String()))
+	}
+	span.End()
+}
@@ -0,0 +1,179 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+// Change classifies a user against the previous snapshot.
+type Change int
+
+const (
+	Unchanged Change = iota
+	Created
+	Updated
+)
+
+func (c Change) String() string {
+	switch c {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	default:
+		return "unchanged"
+	}
+}
+
+// Store holds the users delivered by previous runs, keyed by model.User.Key,
+// and persists them to a JSON file between runs. It is safe for concurrent
+// use.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	users map[string]model.User
+	seen  map[string]struct{}
+}
+
+type file struct {
+	SavedAt time.Time    `json:"saved_at"`
+	Users   []model.User `json:"users"`
+}
+
+// Open loads the snapshot stored at path. A missing file is an empty
+// snapshot, so the first run classifies every user as created.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, users: make(map[string]model.User), seen: make(map[string]struct{})}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, apperrors.SnapshotReadError.AppendMessage(err)
+	}
+
+	var stored file
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, apperrors.SnapshotReadError.AppendMessage(err)
+	}
+	for _, user := range stored.Users {
+		s.users[user.Key()] = user
+	}
+
+	return s, nil
+}
+
+// Begin starts a new diff by forgetting which users were seen so far.
+func (s *Store) Begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen = make(map[string]struct{})
+}
+
+// Observe marks user as present in the current source snapshot.
+func (s *Store) Observe(user model.User) {
+	key := user.Key()
+	if key == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[key] = struct{}{}
+}
+
+// Classify compares user with the version delivered previously.
+func (s *Store) Classify(user model.User) Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.users[user.Key()]
+	switch {
+	case !ok:
+		return Created
+	case !previous.IsEqual(&user):
+		return Updated
+	default:
+		return Unchanged
+	}
+}
+
+// Put records user as delivered.
+func (s *Store) Put(user model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.Key()] = user
+}
+
+// Delete forgets the user stored under key.
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, key)
+}
+
+// Missing returns the stored users not observed since Begin, in key order.
+func (s *Store) Missing() []model.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var missing []model.User
+	for key, user := range s.users {
+		if _, ok := s.seen[key]; !ok {
+			missing = append(missing, user)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Key() < missing[j].Key()
+	})
+
+	return missing
+}
+
+// Save writes the snapshot to its file, replacing it atomically.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	stored := file{SavedAt: time.Now().UTC(), Users: make([]model.User, 0, len(s.users))}
+	for _, user := range s.users {
+		stored.Users = append(stored.Users, user)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(stored.Users, func(i, j int) bool {
+		return stored.Users[i].Key() < stored.Users[j].Key()
+	})
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return apperrors.SnapshotWriteError.AppendMessage(err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return apperrors.SnapshotWriteError.AppendMessage(err)
+	}
+	//nolint:errcheck
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		//nolint:errcheck
+		tmp.Close()
+		return apperrors.SnapshotWriteError.AppendMessage(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return apperrors.SnapshotWriteError.AppendMessage(err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return apperrors.SnapshotWriteError.AppendMessage(err)
+	}
+
+	return nil
+}
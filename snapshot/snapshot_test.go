@@ -0,0 +1,71 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+func TestStore_ClassifyAndMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected open error: %v", err)
+	}
+	john := model.User{Name: "John Doe", Email: "john@test.com"}
+	jane := model.User{Name: "Jane Doe", Email: "jane@test.com"}
+	if got := s.Classify(john); got != Created {
+		t.Errorf("expected %v for an empty snapshot, got %v", Created, got)
+	}
+	s.Put(john)
+	s.Put(jane)
+	if err := s.Save(); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("unexpected reopen error: %v", err)
+	}
+	renamed := model.User{Name: "John Smith", Email: " JOHN@test.com"}
+	tests := []struct {
+		user model.User
+		want Change
+	}{
+		{john, Unchanged},
+		{renamed, Updated},
+		{model.User{Name: "Jim Doe", Email: "jim@test.com"}, Created},
+	}
+	for _, tt := range tests {
+		if got := s.Classify(tt.user); got != tt.want {
+			t.Errorf("Classify(%v) = %v, want %v", tt.user, got, tt.want)
+		}
+	}
+
+	s.Begin()
+	s.Observe(renamed)
+	if got := s.Missing(); !reflect.DeepEqual(got, []model.User{jane}) {
+		t.Errorf("expected only jane to be missing, got %v", got)
+	}
+	s.Delete(jane.Key())
+	if got := s.Missing(); len(got) != 0 {
+		t.Errorf("expected nothing missing after the delete, got %v", got)
+	}
+}
+
+func TestOpen_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := os.WriteFile(path, []byte("{oops"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Open(path)
+	if !apperrors.Is(err, apperrors.SnapshotReadError) {
+		t.Errorf("expected %s, got %v", apperrors.SnapshotReadError.Code, err)
+	}
+}
@@ -0,0 +1,69 @@
+// Package tracing sets up the OpenTelemetry tracer provider the dispatcher
+// and its HTTP client record their spans with.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	// ExporterNone disables tracing.
+	ExporterNone = "none"
+	// ExporterStdout writes finished spans as JSON lines.
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans to an OpenTelemetry collector over OTLP/HTTP.
+	ExporterOTLP = "otlp"
+
+	serviceName = "data-enricher-dispatcher"
+
+	unknownExporterError = "unknown TRACING_EXPORTER %q"
+)
+
+// NewProvider creates a tracer provider exporting spans as TRACING_EXPORTER
+// says, with the stdout exporter writing to out. It returns nil when tracing
+// is disabled. The provider must be shut down to flush the last spans.
+func NewProvider(ctx context.Context, cfg *config.Config, out io.Writer) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.TracingExporter) {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(out))
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.TracingOTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.TracingOTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		err = fmt.Errorf(unknownExporterError, cfg.TracingExporter)
+	}
+	if err != nil {
+		return nil, apperrors.TracingExporterError.AppendMessage(err)
+	}
+
+	attributes := resource.NewSchemaless(semconv.ServiceName(serviceName), semconv.DeploymentEnvironment(cfg.Environment))
+	res, err := resource.Merge(resource.Default(), attributes)
+	if err != nil {
+		return nil, apperrors.TracingExporterError.AppendMessage(err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	), nil
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/config"
+)
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		disabled bool
+		fails    bool
+	}{
+		{name: "none", exporter: ExporterNone, disabled: true},
+		{name: "empty", exporter: "", disabled: true},
+		{name: "stdout", exporter: ExporterStdout},
+		{name: "otlp", exporter: ExporterOTLP},
+		{name: "case insensitive", exporter: "STDOUT"},
+		{name: "unknown", exporter: "zipkin", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Environment: "test", TracingExporter: tt.exporter, TracingSampleRatio: 1, TracingOTLPEndpoint: "http://127.0.0.1:4318"}
+			provider, err := NewProvider(context.Background(), cfg, &bytes.Buffer{})
+			if tt.fails {
+				if !apperrors.Is(err, apperrors.TracingExporterError) {
+					t.Errorf("expected %s, got %v", apperrors.TracingExporterError.Code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (provider == nil) != tt.disabled {
+				t.Errorf("expected disabled %v, got provider %v", tt.disabled, provider)
+			}
+			if provider != nil {
+				//nolint:errcheck
+				provider.Shutdown(context.Background())
+			}
+		})
+	}
+}
+
+func TestNewProvider_Stdout(t *testing.T) {
+	var out bytes.Buffer
+	cfg := &config.Config{Environment: "staging", TracingExporter: ExporterStdout, TracingSampleRatio: 1}
+	provider, err := NewProvider(context.Background(), cfg, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, span := provider.Tracer("test").Start(context.Background(), "dispatch dispatch")
+	span.End()
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	for _, want := range []string{`"Name":"dispatch dispatch"`, `"Value":"staging"`, `"Value":"data-enricher-dispatcher"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %s in the exported span:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+const (
+	OpRename = "rename"
+	OpDrop   = "drop"
+	OpFormat = "format"
+	OpConcat = "concat"
+
+	FormatLower = "lower"
+	FormatUpper = "upper"
+	FormatTrim  = "trim"
+
+	pathSeparator = "."
+
+	unknownOpError     = "rule %d: unknown op %q"
+	missingFieldError  = "rule %d: %s requires %s"
+	unknownFormatError = "rule %d: unknown format %q"
+	notStringError     = "field %s is not a string"
+	notObjectError     = "field %s is not an object"
+)
+
+// Rule is one step of a Pipeline. Fields are addressed by their JSON name;
+// nested fields use dotted paths such as "contact.email".
+//
+//   - rename moves From to To, creating the objects To needs.
+//   - drop removes Field and every entry of Fields.
+//   - format rewrites the string at Field with Format (lower, upper, trim).
+//   - concat joins the non-empty values of Fields with Separator into To.
+type Rule struct {
+	Op        string   `json:"op" yaml:"op"`
+	From      string   `json:"from,omitempty" yaml:"from,omitempty"`
+	To        string   `json:"to,omitempty" yaml:"to,omitempty"`
+	Field     string   `json:"field,omitempty" yaml:"field,omitempty"`
+	Fields    []string `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Format    string   `json:"format,omitempty" yaml:"format,omitempty"`
+	Separator string   `json:"separator,omitempty" yaml:"separator,omitempty"`
+}
+
+// Spec is the content of a transformation file.
+type Spec struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Pipeline turns users into sink payloads by applying its rules in order to
+// the user's JSON form.
+type Pipeline struct {
+	rules []Rule
+}
+
+// Load reads a spec from path, parsed as YAML for .yaml and .yml files and
+// as JSON otherwise, and compiles it into a Pipeline.
+func Load(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, apperrors.TransformLoadError.AppendMessage(err)
+	}
+
+	var spec Spec
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &spec)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&spec)
+	}
+	if err != nil {
+		return nil, apperrors.TransformLoadError.AppendMessage(err, path)
+	}
+
+	return New(spec)
+}
+
+// New validates spec and returns the Pipeline applying it.
+func New(spec Spec) (*Pipeline, error) {
+	for i, rule := range spec.Rules {
+		if err := rule.validate(i); err != nil {
+			return nil, apperrors.TransformInvalidRuleError.AppendMessage(err)
+		}
+	}
+	return &Pipeline{rules: spec.Rules}, nil
+}
+
+func (r Rule) validate(i int) error {
+	switch r.Op {
+	case OpRename:
+		if r.From == "" || r.To == "" {
+			return fmt.Errorf(missingFieldError, i, r.Op, "from and to")
+		}
+	case OpDrop:
+		if r.Field == "" && len(r.Fields) == 0 {
+			return fmt.Errorf(missingFieldError, i, r.Op, "field or fields")
+		}
+	case OpFormat:
+		if r.Field == "" {
+			return fmt.Errorf(missingFieldError, i, r.Op, "field")
+		}
+		switch r.Format {
+		case FormatLower, FormatUpper, FormatTrim:
+		default:
+			return fmt.Errorf(unknownFormatError, i, r.Format)
+		}
+	case OpConcat:
+		if len(r.Fields) == 0 || r.To == "" {
+			return fmt.Errorf(missingFieldError, i, r.Op, "fields and to")
+		}
+	default:
+		return fmt.Errorf(unknownOpError, i, r.Op)
+	}
+	return nil
+}
+
+// Marshal returns the transformed JSON payload of user.
+func (p *Pipeline) Marshal(user model.User) ([]byte, error) {
+	doc, err := p.Apply(user)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// Apply returns the transformed JSON object of user.
+func (p *Pipeline) Apply(user model.User) (map[string]interface{}, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, apperrors.TransformApplyError.AppendMessage(err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, apperrors.TransformApplyError.AppendMessage(err)
+	}
+
+	for _, rule := range p.rules {
+		if err := rule.apply(doc); err != nil {
+			return nil, apperrors.TransformApplyError.AppendMessage(err, user.Email)
+		}
+	}
+
+	return doc, nil
+}
+
+func (r Rule) apply(doc map[string]interface{}) error {
+	switch r.Op {
+	case OpRename:
+		value, ok := get(doc, r.From)
+		if !ok {
+			return nil
+		}
+		remove(doc, r.From)
+		return set(doc, r.To, value)
+	case OpDrop:
+		for _, field := range append([]string{r.Field}, r.Fields...) {
+			if field != "" {
+				remove(doc, field)
+			}
+		}
+	case OpFormat:
+		value, ok := get(doc, r.Field)
+		if !ok || value == nil {
+			return nil
+		}
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf(notStringError, r.Field)
+		}
+		return set(doc, r.Field, format(s, r.Format))
+	case OpConcat:
+		parts := make([]string, 0, len(r.Fields))
+		for _, field := range r.Fields {
+			if value, ok := get(doc, field); ok && value != nil && value != "" {
+				parts = append(parts, fmt.Sprint(value))
+			}
+		}
+		return set(doc, r.To, strings.Join(parts, r.Separator))
+	}
+	return nil
+}
+
+func format(s, f string) string {
+	switch f {
+	case FormatLower:
+		return strings.ToLower(s)
+	case FormatUpper:
+		return strings.ToUpper(s)
+	default:
+		return strings.TrimSpace(s)
+	}
+}
+
+func get(doc map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, pathSeparator)
+	var current interface{} = doc
+	for _, key := range keys {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func set(doc map[string]interface{}, path string, value interface{}) error {
+	keys := strings.Split(path, pathSeparator)
+	object := doc
+	for i, key := range keys[:len(keys)-1] {
+		next, ok := object[key]
+		if !ok || next == nil {
+			child := make(map[string]interface{})
+			object[key] = child
+			object = child
+			continue
+		}
+		if object, ok = next.(map[string]interface{}); !ok {
+			return fmt.Errorf(notObjectError, strings.Join(keys[:i+1], pathSeparator))
+		}
+	}
+	object[keys[len(keys)-1]] = value
+	return nil
+}
+
+func remove(doc map[string]interface{}, path string) {
+	keys := strings.Split(path, pathSeparator)
+	object := doc
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := object[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		object = next
+	}
+	delete(object, keys[len(keys)-1])
+}
@@ -0,0 +1,141 @@
+package transform
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"data-enricher-dispatcher/apperrors"
+	"data-enricher-dispatcher/model"
+)
+
+func TestPipeline_Marshal(t *testing.T) {
+	user := model.User{
+		ID:      1,
+		Name:    "Leanne Graham",
+		Email:   " Sincere@April.biz",
+		Website: "hildegard.org",
+		Address: &model.Address{Street: "Kulas Light", Suite: "Apt. 556", City: "Gwenborough"},
+		Extra:   map[string]json.RawMessage{"tags": json.RawMessage(`["vip"]`)},
+	}
+
+	tests := []struct {
+		name  string
+		rules []Rule
+		want  string
+	}{
+		{
+			name:  "no rules",
+			rules: nil,
+			want:  `{"address":{"city":"Gwenborough","street":"Kulas Light","suite":"Apt. 556"},"email":" Sincere@April.biz","id":1,"name":"Leanne Graham","tags":["vip"],"website":"hildegard.org"}`,
+		},
+		{
+			name: "rename into nested field and format",
+			rules: []Rule{
+				{Op: OpRename, From: "name", To: "full_name"},
+				{Op: OpFormat, Field: "email", Format: FormatTrim},
+				{Op: OpFormat, Field: "email", Format: FormatLower},
+				{Op: OpRename, From: "email", To: "contact.email"},
+				{Op: OpDrop, Fields: []string{"address", "website", "tags"}},
+			},
+			want: `{"contact":{"email":"sincere@april.biz"},"full_name":"Leanne Graham","id":1}`,
+		},
+		{
+			name: "concat skips missing fields",
+			rules: []Rule{
+				{Op: OpConcat, Fields: []string{"address.street", "address.zipcode", "address.city"}, To: "address", Separator: ", "},
+				{Op: OpDrop, Field: "missing.field"},
+				{Op: OpRename, From: "missing", To: "other"},
+				{Op: OpDrop, Fields: []string{"email", "id", "name", "tags", "website"}},
+			},
+			want: `{"address":"Kulas Light, Gwenborough"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := New(Spec{Rules: tt.rules})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := pipeline.Marshal(user)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipeline_MarshalErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{name: "format of a non string", rules: []Rule{{Op: OpFormat, Field: "id", Format: FormatLower}}},
+		{name: "rename below a scalar", rules: []Rule{{Op: OpRename, From: "email", To: "name.email"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := New(Spec{Rules: tt.rules})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, err = pipeline.Marshal(model.User{ID: 1, Name: "Alice", Email: "alice@example.com"})
+			if !apperrors.Is(err, apperrors.TransformApplyError) {
+				t.Errorf("expected %s, got %v", apperrors.TransformApplyError.Code, err)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	tests := []Rule{
+		{Op: "upcase", Field: "name"},
+		{Op: OpRename, From: "name"},
+		{Op: OpDrop},
+		{Op: OpFormat, Field: "name", Format: "title"},
+		{Op: OpConcat, Fields: []string{"name"}},
+	}
+
+	for _, rule := range tests {
+		if _, err := New(Spec{Rules: []Rule{rule}}); !apperrors.Is(err, apperrors.TransformInvalidRuleError) {
+			t.Errorf("expected %s for %+v, got %v", apperrors.TransformInvalidRuleError.Code, rule, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"spec.yaml": "rules:\n  - op: rename\n    from: name\n    to: full_name\n",
+		"spec.json": `{"rules":[{"op":"rename","from":"name","to":"full_name"}]}`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		pipeline, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		got, _ := pipeline.Marshal(model.User{Name: "Alice", Email: "alice@example.com"})
+		if string(got) != `{"email":"alice@example.com","full_name":"Alice"}` {
+			t.Errorf("%s: unexpected payload %s", name, got)
+		}
+	}
+
+	path := filepath.Join(dir, "typo.json")
+	if err := os.WriteFile(path, []byte(`{"rulez":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); !apperrors.Is(err, apperrors.TransformLoadError) {
+		t.Errorf("expected %s for unknown keys, got %v", apperrors.TransformLoadError.Code, err)
+	}
+}